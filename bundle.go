@@ -0,0 +1,206 @@
+package libtrust
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// oidPKCS7SignedData is the content type of PKCS#7 certificate bundles.
+var oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// BundleOptions configure how ReadCertificateBundle handles PEM blocks
+// other than certificates. By default, any such block is an error.
+type BundleOptions struct {
+	// ParseKeys makes private key blocks be parsed and returned with the
+	// certificates.
+	ParseKeys bool
+	// SkipKeys makes private key blocks be ignored. It has no effect if
+	// ParseKeys is set.
+	SkipKeys bool
+	// SkipUnknown makes blocks which are neither certificates nor private
+	// keys be ignored.
+	SkipUnknown bool
+}
+
+// CertificateBundle is the content of a certificate bundle.
+type CertificateBundle struct {
+	// Certificates are the certificates in the order of the bundle.
+	Certificates []*x509.Certificate
+	// Keys are the private keys of the bundle, if parsed.
+	Keys []PrivateKey
+}
+
+// ReadCertificateBundle reads a bundle of certificates which is either PEM
+// encoded, a sequence of DER encoded certificates or a DER encoded PKCS#7
+// (.p7b) bundle. PEM bundles may contain PKCS#7 blocks and private keys,
+// handled according to the given options.
+func ReadCertificateBundle(r io.Reader, opts *BundleOptions) (*CertificateBundle, error) {
+	if opts == nil {
+		opts = &BundleOptions{}
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	block, rest := pem.Decode(content)
+	if block == nil {
+		certs, err := parseDERBundle(content)
+		if err != nil {
+			return nil, err
+		}
+		return &CertificateBundle{Certificates: certs}, nil
+	}
+
+	bundle := &CertificateBundle{}
+	for ; block != nil; block, rest = pem.Decode(rest) {
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			bundle.Certificates = append(bundle.Certificates, cert)
+		case block.Type == "PKCS7":
+			certs, err := parsePKCS7Bundle(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			bundle.Certificates = append(bundle.Certificates, certs...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			if opts.ParseKeys {
+				key, err := parsePrivateKeyBlock(block)
+				if err != nil {
+					return nil, err
+				}
+				bundle.Keys = append(bundle.Keys, key)
+			} else if !opts.SkipKeys {
+				return nil, fmt.Errorf("unexpected private key in certificate bundle: %s", block.Type)
+			}
+		default:
+			if !opts.SkipUnknown {
+				return nil, fmt.Errorf("invalid pem block type: %s", block.Type)
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// parseDERBundle parses DER encoded certificates or a PKCS#7 bundle.
+func parseDERBundle(content []byte) ([]*x509.Certificate, error) {
+	certs, err := x509.ParseCertificates(content)
+	if err == nil {
+		return certs, nil
+	}
+	certs, pkcs7Err := parsePKCS7Bundle(content)
+	if pkcs7Err != nil {
+		return nil, fmt.Errorf("unable to parse certificate bundle: %s", err)
+	}
+	return certs, nil
+}
+
+// parsePKCS7Bundle returns the certificates of a DER encoded degenerate
+// PKCS#7 SignedData structure, as found in .p7b files.
+func parsePKCS7Bundle(der []byte) ([]*x509.Certificate, error) {
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("malformed PKCS#7 bundle: %s", err)
+	}
+	if !info.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, fmt.Errorf("unsupported PKCS#7 content type %s", info.ContentType)
+	}
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("malformed PKCS#7 signed data: %s", err)
+	}
+	return x509.ParseCertificates(signedData.Certificates.Bytes)
+}
+
+// parsePrivateKeyBlock parses a PKCS#1, SEC 1 or PKCS#8 private key block.
+func parsePrivateKeyBlock(block *pem.Block) (PrivateKey, error) {
+	if block.Type != "PRIVATE KEY" {
+		return UnmarshalPrivateKeyPEM(pem.EncodeToMemory(block))
+	}
+	cryptoKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode PKCS#8 Private Key PEM data: %s", err)
+	}
+	return FromCryptoPrivateKey(cryptoKey)
+}
+
+// SortCertificateChain orders the certificates of a single chain from the
+// leaf to the root, or the last available issuer, as expected by
+// SignWithChain. Certificates which are not part of the chain are an
+// error.
+func SortCertificateChain(certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates")
+	}
+
+	issuerOf := func(cert *x509.Certificate) *x509.Certificate {
+		for _, candidate := range certs {
+			if candidate != cert && bytes.Equal(cert.RawIssuer, candidate.RawSubject) && cert.CheckSignatureFrom(candidate) == nil {
+				return candidate
+			}
+		}
+		return nil
+	}
+
+	issuers := make(map[*x509.Certificate]bool, len(certs))
+	for _, cert := range certs {
+		if issuer := issuerOf(cert); issuer != nil {
+			issuers[issuer] = true
+		}
+	}
+	var leaf *x509.Certificate
+	for _, cert := range certs {
+		if issuers[cert] {
+			continue
+		}
+		if leaf != nil {
+			return nil, errors.New("certificates form more than one chain")
+		}
+		leaf = cert
+	}
+	if leaf == nil {
+		return nil, errors.New("certificates do not form a chain")
+	}
+
+	chain := []*x509.Certificate{leaf}
+	inChain := map[*x509.Certificate]bool{leaf: true}
+	for issuer := issuerOf(leaf); issuer != nil && !inChain[issuer]; issuer = issuerOf(issuer) {
+		chain = append(chain, issuer)
+		inChain[issuer] = true
+	}
+	if len(chain) != len(certs) {
+		return nil, errors.New("certificates form more than one chain")
+	}
+	return chain, nil
+}
+
+// SigningChain returns the certificates of the bundle sorted into a chain
+// for SignWithChain.
+func (b *CertificateBundle) SigningChain() ([]*x509.Certificate, error) {
+	return SortCertificateChain(b.Certificates)
+}
@@ -0,0 +1,160 @@
+package libtrust
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust/testutil"
+)
+
+func generateTestBundleChain(t *testing.T) (PrivateKey, []*x509.Certificate) {
+	now := time.Now()
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating CA: %s", err)
+	}
+	intermediateKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	intermediate, err := testutil.GenerateIntermediate(intermediateKey.CryptoPublicKey(), caKey.CryptoPrivateKey(), ca)
+	if err != nil {
+		t.Fatalf("Error generating intermediate: %s", err)
+	}
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	leaf := generateTestCert(t, key.PublicKey(), intermediateKey, intermediate, now.Add(-time.Second), now.Add(time.Hour), false)
+	return key, []*x509.Certificate{leaf, intermediate, ca}
+}
+
+func TestReadCertificateBundle(t *testing.T) {
+	key, chain := generateTestBundleChain(t)
+	keyBlock, err := key.PEMBlock()
+	if err != nil {
+		t.Fatalf("Error encoding key: %s", err)
+	}
+
+	// A combined key and unordered certificates file.
+	var combined bytes.Buffer
+	pem.Encode(&combined, keyBlock)
+	for _, i := range []int{2, 0, 1} {
+		pem.Encode(&combined, &pem.Block{Type: "CERTIFICATE", Bytes: chain[i].Raw})
+	}
+	pem.Encode(&combined, &pem.Block{Type: "X509 CRL", Bytes: []byte{0}})
+
+	if _, err := ReadCertificateBundle(bytes.NewReader(combined.Bytes()), nil); err == nil {
+		t.Fatalf("Expected error reading bundle with private key")
+	}
+	if _, err := ReadCertificateBundle(bytes.NewReader(combined.Bytes()), &BundleOptions{SkipKeys: true}); err == nil {
+		t.Fatalf("Expected error reading bundle with unknown block")
+	}
+	bundle, err := ReadCertificateBundle(bytes.NewReader(combined.Bytes()), &BundleOptions{SkipKeys: true, SkipUnknown: true})
+	if err != nil {
+		t.Fatalf("Error reading bundle: %s", err)
+	}
+	if len(bundle.Certificates) != 3 || len(bundle.Keys) != 0 {
+		t.Fatalf("Unexpected bundle with %d certificates and %d keys", len(bundle.Certificates), len(bundle.Keys))
+	}
+	bundle, err = ReadCertificateBundle(bytes.NewReader(combined.Bytes()), &BundleOptions{ParseKeys: true, SkipUnknown: true})
+	if err != nil {
+		t.Fatalf("Error reading bundle: %s", err)
+	}
+	if len(bundle.Keys) != 1 || bundle.Keys[0].KeyID() != key.KeyID() {
+		t.Fatalf("Expected key %s in bundle", key.KeyID())
+	}
+
+	sorted, err := bundle.SigningChain()
+	if err != nil {
+		t.Fatalf("Error sorting chain: %s", err)
+	}
+	for i := range chain {
+		if !sorted[i].Equal(chain[i]) {
+			t.Fatalf("Certificate %d out of order", i)
+		}
+	}
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(bundle.Keys[0], sorted); err != nil {
+		t.Fatalf("Error signing with sorted chain: %s", err)
+	}
+
+	// DER encoded certificates.
+	var der []byte
+	for _, cert := range chain {
+		der = append(der, cert.Raw...)
+	}
+	bundle, err = ReadCertificateBundle(bytes.NewReader(der), nil)
+	if err != nil {
+		t.Fatalf("Error reading DER bundle: %s", err)
+	}
+	if len(bundle.Certificates) != 3 {
+		t.Fatalf("Expected 3 certificates in DER bundle, got %d", len(bundle.Certificates))
+	}
+
+	// PKCS#7 bundles, DER and PEM encoded.
+	content, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo:      asn1.RawValue{FullBytes: []byte{0x30, 0x0b, 0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x07, 0x01}},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der},
+		SignerInfos:      asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+	})
+	if err != nil {
+		t.Fatalf("Error encoding PKCS#7 signed data: %s", err)
+	}
+	p7b, err := asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7SignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+	if err != nil {
+		t.Fatalf("Error encoding PKCS#7 bundle: %s", err)
+	}
+	for _, encoded := range [][]byte{p7b, pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: p7b})} {
+		bundle, err = ReadCertificateBundle(bytes.NewReader(encoded), nil)
+		if err != nil {
+			t.Fatalf("Error reading PKCS#7 bundle: %s", err)
+		}
+		if len(bundle.Certificates) != 3 {
+			t.Fatalf("Expected 3 certificates in PKCS#7 bundle, got %d", len(bundle.Certificates))
+		}
+	}
+
+	if _, err := ReadCertificateBundle(bytes.NewReader([]byte("not a bundle")), nil); err == nil {
+		t.Fatalf("Expected error reading invalid bundle")
+	}
+}
+
+func TestSortCertificateChain(t *testing.T) {
+	_, chain := generateTestBundleChain(t)
+	_, otherChain := generateTestBundleChain(t)
+
+	sorted, err := SortCertificateChain([]*x509.Certificate{chain[1], chain[0]})
+	if err != nil {
+		t.Fatalf("Error sorting chain without root: %s", err)
+	}
+	if !sorted[0].Equal(chain[0]) || !sorted[1].Equal(chain[1]) {
+		t.Fatalf("Chain without root out of order")
+	}
+	if _, err := SortCertificateChain([]*x509.Certificate{chain[0], otherChain[1]}); err == nil {
+		t.Fatalf("Expected error sorting unrelated certificates")
+	}
+	if _, err := SortCertificateChain(append(chain, otherChain[2])); err == nil {
+		t.Fatalf("Expected error sorting certificates from two chains")
+	}
+	if _, err := SortCertificateChain(nil); err == nil {
+		t.Fatalf("Expected error sorting no certificates")
+	}
+}
@@ -0,0 +1,288 @@
+package libtrust
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Defaults of a CertificateAuthority.
+const (
+	DefaultIssueValidity = time.Hour * 24 * 365
+	DefaultCRLValidity   = time.Hour * 24
+)
+
+// IssueOptions describe a certificate issued by a CertificateAuthority.
+// The serial number, validity, subject and other fields of the embedded
+// CertificateOptions default as for the CertificateAuthority.
+type IssueOptions struct {
+	CertificateOptions
+
+	// DNSNames are DNS subject alternative names.
+	DNSNames []string
+	// IPAddresses are IP address subject alternative names.
+	IPAddresses []net.IP
+	// ExtKeyUsages are the extended key usages of a leaf certificate. If
+	// empty, both server and client authentication are allowed.
+	ExtKeyUsages []x509.ExtKeyUsage
+}
+
+// CertificateAuthority issues leaf and intermediate certificates signed by
+// a libtrust key, records them in a CertificateStore and generates
+// revocation lists for them.
+type CertificateAuthority struct {
+	key   PrivateKey
+	chain []*x509.Certificate
+	store CertificateStore
+
+	// Validity is the default validity of issued certificates. It
+	// defaults to DefaultIssueValidity. Certificates never outlive the
+	// certificate of the authority.
+	Validity time.Duration
+	// CRLValidity is the time after which generated revocation lists are
+	// stale. It defaults to DefaultCRLValidity.
+	CRLValidity time.Duration
+	// Now returns the time at which certificates and lists are issued. It
+	// defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewRootCertificateAuthority creates a self-signed root certificate for
+// the given key, customized by the given options, and returns an authority
+// using it.
+func NewRootCertificateAuthority(key PrivateKey, opts *CertificateOptions, store CertificateStore) (*CertificateAuthority, error) {
+	template, err := generateCertTemplate(&certTemplateInfo{
+		key:        key.PublicKey(),
+		commonName: key.KeyID(),
+		isCA:       true,
+		options:    opts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	template.KeyUsage |= x509.KeyUsageCRLSign
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.CryptoPublicKey(), key.CryptoPrivateKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %s", err)
+	}
+	if err := store.Add(cert); err != nil {
+		return nil, err
+	}
+
+	return NewCertificateAuthority(key, []*x509.Certificate{cert}, store)
+}
+
+// NewCertificateAuthority returns an authority for an existing CA
+// certificate of the given key. The chain starts with that certificate,
+// followed by its issuers up to the root.
+func NewCertificateAuthority(key PrivateKey, chain []*x509.Certificate, store CertificateStore) (*CertificateAuthority, error) {
+	// CA certificates need not allow digital signatures.
+	if err := checkKeyChain(key, chain, time.Now()); err != nil {
+		return nil, err
+	}
+	if !chain[0].IsCA || chain[0].KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, errors.New("certificate is not allowed to issue certificates")
+	}
+	if _, ok := key.CryptoPrivateKey().(crypto.Signer); !ok {
+		return nil, errors.New("key cannot sign certificates")
+	}
+
+	return &CertificateAuthority{
+		key:         key,
+		chain:       chain,
+		store:       store,
+		Validity:    DefaultIssueValidity,
+		CRLValidity: DefaultCRLValidity,
+		Now:         time.Now,
+	}, nil
+}
+
+// Certificate returns the certificate of the authority.
+func (ca *CertificateAuthority) Certificate() *x509.Certificate {
+	return ca.chain[0]
+}
+
+// Chain returns the certificate of the authority followed by its issuers.
+// Appended to an issued leaf certificate, it is a chain for
+// SignWithChain.
+func (ca *CertificateAuthority) Chain() []*x509.Certificate {
+	return append([]*x509.Certificate(nil), ca.chain...)
+}
+
+// CertPool returns a pool with the root certificate of the authority, to
+// verify the certificates it issues.
+func (ca *CertificateAuthority) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.chain[len(ca.chain)-1])
+	return pool
+}
+
+// IssueLeaf issues a leaf certificate for the given key.
+func (ca *CertificateAuthority) IssueLeaf(key PublicKey, opts *IssueOptions) (*x509.Certificate, error) {
+	return ca.issue(key, false, opts)
+}
+
+// IssueIntermediate issues an intermediate CA certificate for the given
+// key. A NewCertificateAuthority for the key with the returned certificate
+// followed by Chain can then issue certificates in turn.
+func (ca *CertificateAuthority) IssueIntermediate(key PublicKey, opts *IssueOptions) (*x509.Certificate, error) {
+	if ca.chain[0].MaxPathLen == 0 && ca.chain[0].MaxPathLenZero {
+		return nil, errors.New("authority is not allowed to issue intermediate certificates")
+	}
+	return ca.issue(key, true, opts)
+}
+
+// IssueLeafFromCSR issues a leaf certificate for the key of a certificate
+// signing request after checking its signature. The subject and subject
+// alternative names of the request are used unless set in opts.
+//
+// The signature only proves possession of the key: the requested names are
+// not checked in any way. Callers must either vet them before issuing or
+// set the subject and names in opts.
+func (ca *CertificateAuthority) IssueLeafFromCSR(csr *x509.CertificateRequest, opts *IssueOptions) (*x509.Certificate, error) {
+	key, opts, err := csrIssueOptions(csr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ca.IssueLeaf(key, opts)
+}
+
+// IssueIntermediateFromCSR issues an intermediate CA certificate for the
+// key of a certificate signing request after checking its signature. As
+// with IssueLeafFromCSR, the requested subject and names are used unchecked
+// unless set in opts, so callers must vet them.
+func (ca *CertificateAuthority) IssueIntermediateFromCSR(csr *x509.CertificateRequest, opts *IssueOptions) (*x509.Certificate, error) {
+	key, opts, err := csrIssueOptions(csr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ca.IssueIntermediate(key, opts)
+}
+
+// csrIssueOptions returns the key of a certificate signing request and
+// the options completed with its requested attributes.
+func csrIssueOptions(csr *x509.CertificateRequest, opts *IssueOptions) (PublicKey, *IssueOptions, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate signing request signature: %s", err)
+	}
+	key, err := FromCryptoPublicKey(csr.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	completed := IssueOptions{}
+	if opts != nil {
+		completed = *opts
+	}
+	if completed.Subject.String() == "" {
+		completed.Subject = csr.Subject
+	}
+	if completed.DNSNames == nil {
+		completed.DNSNames = csr.DNSNames
+	}
+	if completed.IPAddresses == nil {
+		completed.IPAddresses = csr.IPAddresses
+	}
+	if completed.URIs == nil {
+		completed.URIs = csr.URIs
+	}
+	if completed.EmailAddresses == nil {
+		completed.EmailAddresses = csr.EmailAddresses
+	}
+	return key, &completed, nil
+}
+
+// issue creates, signs and records a certificate for the given key.
+func (ca *CertificateAuthority) issue(key PublicKey, isCA bool, opts *IssueOptions) (*x509.Certificate, error) {
+	issueOpts := IssueOptions{}
+	if opts != nil {
+		issueOpts = *opts
+	}
+	certOpts := issueOpts.CertificateOptions
+
+	now := ca.Now()
+	if certOpts.NotBefore.IsZero() {
+		certOpts.NotBefore = now
+	}
+	if certOpts.NotAfter.IsZero() {
+		certOpts.NotAfter = certOpts.NotBefore.Add(ca.Validity)
+	}
+	if certOpts.NotAfter.After(ca.chain[0].NotAfter) {
+		certOpts.NotAfter = ca.chain[0].NotAfter
+	}
+
+	template, err := generateCertTemplate(&certTemplateInfo{
+		key:         key,
+		commonName:  key.KeyID(),
+		domains:     issueOpts.DNSNames,
+		ipAddresses: issueOpts.IPAddresses,
+		isCA:        isCA,
+		options:     &certOpts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = issueOpts.ExtKeyUsages
+		if len(template.ExtKeyUsage) == 0 {
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.chain[0], key.CryptoPublicKey(), ca.key.CryptoPrivateKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %s", err)
+	}
+	if err := ca.store.Add(cert); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// Revoke revokes a certificate issued by the authority.
+func (ca *CertificateAuthority) Revoke(cert *x509.Certificate) error {
+	return ca.store.Revoke(cert.SerialNumber, ca.Now())
+}
+
+// GenerateCRL returns a DER encoded revocation list of the revoked
+// certificates, signed by the authority. It can be loaded into a CRLStore.
+func (ca *CertificateAuthority) GenerateCRL() ([]byte, error) {
+	entries, err := ca.store.Revoked()
+	if err != nil {
+		return nil, err
+	}
+	number, err := ca.store.NextCRLNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := ca.Now()
+	template := &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(ca.CRLValidity),
+		RevokedCertificateEntries: entries,
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, ca.chain[0], ca.key.CryptoPrivateKey().(crypto.Signer))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create revocation list: %s", err)
+	}
+	return crl, nil
+}
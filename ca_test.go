@@ -0,0 +1,178 @@
+package libtrust
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCertificateAuthority(t *testing.T) {
+	rootKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	root, err := NewRootCertificateAuthority(rootKey, &CertificateOptions{Subject: pkix.Name{CommonName: "Test Root"}}, NewMemoryCertificateStore())
+	if err != nil {
+		t.Fatalf("Error creating root authority: %s", err)
+	}
+	if root.Certificate().Subject.CommonName != "Test Root" {
+		t.Fatalf("Unexpected root subject %s", root.Certificate().Subject)
+	}
+
+	intermediateKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	intermediateOpts := &IssueOptions{CertificateOptions: CertificateOptions{MaxPathLenZero: true}}
+	intermediateCert, err := root.IssueIntermediate(intermediateKey.PublicKey(), intermediateOpts)
+	if err != nil {
+		t.Fatalf("Error issuing intermediate: %s", err)
+	}
+	intermediate, err := NewCertificateAuthority(intermediateKey, append([]*x509.Certificate{intermediateCert}, root.Chain()...), NewMemoryCertificateStore())
+	if err != nil {
+		t.Fatalf("Error creating intermediate authority: %s", err)
+	}
+	if _, err := intermediate.IssueIntermediate(rootKey.PublicKey(), nil); err == nil {
+		t.Fatalf("Expected error issuing intermediate below path length limit")
+	}
+
+	signerKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	leaf, err := intermediate.IssueLeaf(signerKey.PublicKey(), &IssueOptions{DNSNames: []string{"localhost"}})
+	if err != nil {
+		t.Fatalf("Error issuing leaf: %s", err)
+	}
+	if leaf.Subject.CommonName != signerKey.KeyID() || leaf.NotAfter.After(intermediateCert.NotAfter) {
+		t.Fatalf("Unexpected leaf certificate %s valid until %s", leaf.Subject, leaf.NotAfter)
+	}
+
+	// Issued certificates can sign content.
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(signerKey, append([]*x509.Certificate{leaf}, intermediate.Chain()...)); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	opts := ChainVerifyOptions{Roots: root.CertPool(), DNSName: "localhost"}
+	if _, err := js.VerifyChainsWithOptions(opts); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+
+	// Certificates are issued from signing requests.
+	csrKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: "requested"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, csrKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error creating signing request: %s", err)
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		t.Fatalf("Error parsing signing request: %s", err)
+	}
+	csrCert, err := intermediate.IssueLeafFromCSR(csr, &IssueOptions{ExtKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	if err != nil {
+		t.Fatalf("Error issuing from signing request: %s", err)
+	}
+	if csrCert.Subject.CommonName != "requested" || len(csrCert.IPAddresses) != 1 || len(csrCert.ExtKeyUsage) != 1 {
+		t.Fatalf("Unexpected certificate issued from signing request: %s %v %v", csrCert.Subject, csrCert.IPAddresses, csrCert.ExtKeyUsage)
+	}
+	csr.Signature[len(csr.Signature)-1] ^= 0xff
+	if _, err := intermediate.IssueLeafFromCSR(csr, nil); err == nil {
+		t.Fatalf("Expected error issuing from tampered signing request")
+	}
+
+	// Revoked certificates are listed in generated revocation lists.
+	if err := intermediate.Revoke(leaf); err != nil {
+		t.Fatalf("Error revoking certificate: %s", err)
+	}
+	if err := intermediate.Revoke(leaf); err != ErrAlreadyRevoked {
+		t.Fatalf("Expected already revoked error, got %v", err)
+	}
+	if err := intermediate.Revoke(intermediateCert); err != ErrUnknownSerial {
+		t.Fatalf("Expected unknown serial error, got %v", err)
+	}
+	store := NewCRLStore(FailClosed)
+	for _, ca := range []*CertificateAuthority{root, intermediate} {
+		crl, err := ca.GenerateCRL()
+		if err != nil {
+			t.Fatalf("Error generating revocation list: %s", err)
+		}
+		if err := store.AddCRL(crl); err != nil {
+			t.Fatalf("Error adding revocation list: %s", err)
+		}
+	}
+	opts.Revocation = store
+	_, err = js.VerifyChainsWithOptions(opts)
+	var revokedErr *RevokedError
+	if !errors.As(err, &revokedErr) || !revokedErr.Certificate.Equal(leaf) {
+		t.Fatalf("Expected revoked error for leaf, got %v", err)
+	}
+}
+
+func TestNewCertificateAuthorityChain(t *testing.T) {
+	rootKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	root, err := NewRootCertificateAuthority(rootKey, nil, NewMemoryCertificateStore())
+	if err != nil {
+		t.Fatalf("Error creating root authority: %s", err)
+	}
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	other, err := NewRootCertificateAuthority(otherKey, nil, NewMemoryCertificateStore())
+	if err != nil {
+		t.Fatalf("Error creating root authority: %s", err)
+	}
+	intermediateKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	expectChainProblem := func(chain []*x509.Certificate, problem ChainProblem) {
+		_, err := NewCertificateAuthority(intermediateKey, chain, NewMemoryCertificateStore())
+		var chainErr *ChainError
+		if !errors.As(err, &chainErr) || chainErr.Problem != problem {
+			t.Fatalf("Expected %s chain error, got %v", problem, err)
+		}
+	}
+
+	// CA certificates without the digitalSignature usage have their
+	// validity and issuers checked.
+	now := time.Now()
+	expiredCert, err := root.IssueIntermediate(intermediateKey.PublicKey(), &IssueOptions{CertificateOptions: CertificateOptions{
+		NotBefore: now.Add(-2 * time.Hour),
+		NotAfter:  now.Add(-time.Hour),
+	}})
+	if err != nil {
+		t.Fatalf("Error issuing intermediate: %s", err)
+	}
+	if expiredCert.KeyUsage&x509.KeyUsageDigitalSignature != 0 {
+		t.Fatalf("Expected intermediate certificate without digitalSignature usage")
+	}
+	expectChainProblem(append([]*x509.Certificate{expiredCert}, root.Chain()...), ChainValidity)
+
+	intermediateCert, err := root.IssueIntermediate(intermediateKey.PublicKey(), nil)
+	if err != nil {
+		t.Fatalf("Error issuing intermediate: %s", err)
+	}
+	expectChainProblem(append([]*x509.Certificate{intermediateCert}, other.Chain()...), ChainOrder)
+	if _, err := NewCertificateAuthority(intermediateKey, append([]*x509.Certificate{intermediateCert}, root.Chain()...), NewMemoryCertificateStore()); err != nil {
+		t.Fatalf("Error creating intermediate authority: %s", err)
+	}
+}
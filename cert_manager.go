@@ -0,0 +1,258 @@
+package libtrust
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultRenewalCheckInterval is the default time between checks of the
+// certificate of a started CertificateManager.
+const DefaultRenewalCheckInterval = time.Minute
+
+// CertificateSource obtains a new certificate for a key, returned as a
+// chain starting with the leaf certificate.
+type CertificateSource func(key PrivateKey) ([]*x509.Certificate, error)
+
+// SelfSignedServerCertSource returns a source of self-signed server
+// certificates for the given domains and IP addresses, valid for the given
+// duration from the time they are generated.
+func SelfSignedServerCertSource(domains []string, ipAddresses []net.IP, validity time.Duration) CertificateSource {
+	return func(key PrivateKey) ([]*x509.Certificate, error) {
+		now := time.Now()
+		cert, err := GenerateSelfSignedServerCertWithOptions(key, domains, ipAddresses, &CertificateOptions{NotBefore: now, NotAfter: now.Add(validity)})
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+}
+
+// SelfSignedClientCertSource returns a source of self-signed client
+// certificates valid for the given duration from the time they are
+// generated.
+func SelfSignedClientCertSource(validity time.Duration) CertificateSource {
+	return func(key PrivateKey) ([]*x509.Certificate, error) {
+		now := time.Now()
+		cert, err := GenerateSelfSignedClientCertWithOptions(key, &CertificateOptions{NotBefore: now, NotAfter: now.Add(validity)})
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+}
+
+// CertificateAuthoritySource returns a source of leaf certificates issued
+// by the given authority with the given options, followed by the chain of
+// the authority.
+func CertificateAuthoritySource(ca *CertificateAuthority, opts *IssueOptions) CertificateSource {
+	return func(key PrivateKey) ([]*x509.Certificate, error) {
+		cert, err := ca.IssueLeaf(key.PublicKey(), opts)
+		if err != nil {
+			return nil, err
+		}
+		return append([]*x509.Certificate{cert}, ca.Chain()...), nil
+	}
+}
+
+// CertificateManager keeps a TLS certificate for a key current, obtaining
+// a new one from its source before the current one expires. It serves the
+// certificate through the GetCertificate and GetClientCertificate fields of
+// tls.Config, so that long-running servers and clients pick up renewed
+// certificates without being reconfigured.
+type CertificateManager struct {
+	key    PrivateKey
+	source CertificateSource
+
+	// RenewBefore is how long before expiry the certificate is renewed. If
+	// zero, it is renewed after two thirds of its validity period.
+	RenewBefore time.Duration
+	// CheckInterval is the time between checks of the certificate once
+	// started. It defaults to DefaultRenewalCheckInterval.
+	CheckInterval time.Duration
+	// OnRenewal, if not nil, is called with each renewed leaf certificate.
+	OnRenewal func(cert *x509.Certificate)
+	// OnRenewalError, if not nil, is called when renewal fails. The
+	// current certificate keeps being served and renewal is retried at
+	// the next check.
+	//
+	// For background checks, OnRenewal and OnRenewalError are called on
+	// their own goroutine, so that they may block without delaying further
+	// checks or Stop.
+	OnRenewalError func(err error)
+	// Now returns the time used to decide on renewal. It defaults to
+	// time.Now.
+	Now func() time.Time
+
+	certLock sync.RWMutex
+	current  *tls.Certificate
+
+	runLock sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewCertificateManager returns a manager of the certificates obtained from
+// the given source for the key, with an initial certificate.
+func NewCertificateManager(key PrivateKey, source CertificateSource) (*CertificateManager, error) {
+	m := &CertificateManager{
+		key:           key,
+		source:        source,
+		CheckInterval: DefaultRenewalCheckInterval,
+		Now:           time.Now,
+	}
+	if err := m.Renew(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Certificate returns the current certificate.
+func (m *CertificateManager) Certificate() *tls.Certificate {
+	m.certLock.RLock()
+	defer m.certLock.RUnlock()
+	return m.current
+}
+
+// GetCertificate returns the current certificate. It has the signature of
+// the GetCertificate field of tls.Config.
+func (m *CertificateManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return m.Certificate(), nil
+}
+
+// GetClientCertificate returns the current certificate. It has the
+// signature of the GetClientCertificate field of tls.Config.
+func (m *CertificateManager) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return m.Certificate(), nil
+}
+
+// ConfigureServerTLS makes the given server configuration, such as one
+// returned by NewIdentityAuthTLSConfig, serve the managed certificate.
+func (m *CertificateManager) ConfigureServerTLS(tlsConfig *tls.Config) {
+	tlsConfig.Certificates = nil
+	tlsConfig.GetCertificate = m.GetCertificate
+}
+
+// ConfigureClientTLS makes the given client configuration, such as one
+// returned by NewIdentityAuthTLSClientConfig, present the managed
+// certificate.
+func (m *CertificateManager) ConfigureClientTLS(tlsConfig *tls.Config) {
+	tlsConfig.Certificates = nil
+	tlsConfig.GetClientCertificate = m.GetClientCertificate
+}
+
+// Renew obtains a new certificate from the source and serves it from now
+// on.
+func (m *CertificateManager) Renew() error {
+	leaf, err := m.renew()
+	if err != nil {
+		return err
+	}
+	if m.OnRenewal != nil {
+		m.OnRenewal(leaf)
+	}
+	return nil
+}
+
+// renew obtains and serves a new certificate, returning its leaf.
+func (m *CertificateManager) renew() (*x509.Certificate, error) {
+	chain, err := m.source(m.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain certificate: %s", err)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("failed to obtain certificate: empty chain")
+	}
+	leafKey, err := FromCryptoPublicKey(chain[0].PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if leafKey.KeyID() != m.key.KeyID() {
+		return nil, fmt.Errorf("obtained certificate is for key %s, not %s", leafKey.KeyID(), m.key.KeyID())
+	}
+
+	cert := &tls.Certificate{
+		PrivateKey: m.key.CryptoPrivateKey(),
+		Leaf:       chain[0],
+	}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+
+	m.certLock.Lock()
+	m.current = cert
+	m.certLock.Unlock()
+	return chain[0], nil
+}
+
+// NeedsRenewal returns whether the current certificate is due for renewal.
+func (m *CertificateManager) NeedsRenewal() bool {
+	leaf := m.Certificate().Leaf
+	renewBefore := m.RenewBefore
+	if renewBefore == 0 {
+		renewBefore = leaf.NotAfter.Sub(leaf.NotBefore) / 3
+	}
+	return !m.Now().Before(leaf.NotAfter.Add(-renewBefore))
+}
+
+// check renews the certificate if it is due, reporting renewals and
+// failures on another goroutine.
+func (m *CertificateManager) check() {
+	if !m.NeedsRenewal() {
+		return
+	}
+	leaf, err := m.renew()
+	if err != nil {
+		if m.OnRenewalError != nil {
+			go m.OnRenewalError(err)
+		}
+		return
+	}
+	if m.OnRenewal != nil {
+		go m.OnRenewal(leaf)
+	}
+}
+
+// Start checks the certificate in the background every CheckInterval,
+// renewing it when due, until Stop is called.
+func (m *CertificateManager) Start() {
+	m.runLock.Lock()
+	defer m.runLock.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(m.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-stop:
+				return
+			}
+		}
+	}(m.stop, m.done)
+}
+
+// Stop stops background checks and waits for a running check to finish,
+// without waiting for the callbacks it started.
+func (m *CertificateManager) Stop() {
+	m.runLock.Lock()
+	defer m.runLock.Unlock()
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+	m.done = nil
+}
@@ -0,0 +1,189 @@
+package libtrust
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCertificateManager(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	manager, err := NewCertificateManager(key, SelfSignedServerCertSource([]string{"localhost"}, nil, time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating certificate manager: %s", err)
+	}
+	tlsConfig := newTLSConfig()
+	tlsConfig.Certificates = []tls.Certificate{{}}
+	manager.ConfigureServerTLS(tlsConfig)
+	first, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("Error getting certificate: %s", err)
+	}
+	if len(tlsConfig.Certificates) != 0 || first.Leaf.DNSNames[0] != "localhost" {
+		t.Fatalf("Unexpected served certificate")
+	}
+
+	var lock sync.Mutex
+	now := time.Now()
+	manager.Now = func() time.Time {
+		lock.Lock()
+		defer lock.Unlock()
+		return now
+	}
+	renewed := make(chan *x509.Certificate, 1)
+	manager.OnRenewal = func(cert *x509.Certificate) {
+		// Renewed certificates start at the real time: move the clock back
+		// so that they are not due for renewal themselves.
+		lock.Lock()
+		now = time.Now()
+		lock.Unlock()
+		select {
+		case renewed <- cert:
+		default:
+		}
+	}
+
+	// The certificate is renewed after two thirds of its validity.
+	if manager.NeedsRenewal() {
+		t.Fatalf("Unexpected renewal of a new certificate")
+	}
+	lock.Lock()
+	now = now.Add(41 * time.Minute)
+	lock.Unlock()
+	if !manager.NeedsRenewal() {
+		t.Fatalf("Expected renewal of an aging certificate")
+	}
+	manager.CheckInterval = time.Millisecond
+	manager.Start()
+	select {
+	case cert := <-renewed:
+		if cert.SerialNumber.Cmp(first.Leaf.SerialNumber) == 0 {
+			t.Fatalf("Renewed certificate has the same serial number")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Certificate was not renewed")
+	}
+	manager.Stop()
+	served, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("Error getting certificate: %s", err)
+	}
+	if served == first {
+		t.Fatalf("Renewed certificate is not served")
+	}
+
+	// Failures are reported and the current certificate kept.
+	failure := errors.New("source unavailable")
+	manager.source = func(PrivateKey) ([]*x509.Certificate, error) {
+		return nil, failure
+	}
+	manager.RenewBefore = 2 * time.Hour
+	reported := make(chan error, 1)
+	manager.OnRenewalError = func(err error) {
+		reported <- err
+	}
+	manager.check()
+	select {
+	case err := <-reported:
+		if err == nil {
+			t.Fatalf("Expected reported renewal failure")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Renewal failure was not reported")
+	}
+	if manager.Certificate() != served {
+		t.Fatalf("Current certificate replaced after renewal failure")
+	}
+
+	// Certificates for another key are rejected.
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	manager.source = func(PrivateKey) ([]*x509.Certificate, error) {
+		return SelfSignedClientCertSource(time.Hour)(otherKey)
+	}
+	if err := manager.Renew(); err == nil {
+		t.Fatalf("Expected error renewing with certificate for another key")
+	}
+}
+
+func TestCertificateManagerStopWithBlockedCallback(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	manager, err := NewCertificateManager(key, SelfSignedServerCertSource([]string{"localhost"}, nil, time.Hour))
+	if err != nil {
+		t.Fatalf("Error creating certificate manager: %s", err)
+	}
+	// Every check renews the certificate and calls a callback which never
+	// returns.
+	manager.RenewBefore = 2 * time.Hour
+	manager.CheckInterval = time.Millisecond
+	called := make(chan struct{}, 1)
+	block := make(chan struct{})
+	defer close(block)
+	manager.OnRenewal = func(*x509.Certificate) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		<-block
+	}
+	manager.Start()
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Certificate was not renewed")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		manager.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Stop blocked on renewal callback")
+	}
+}
+
+func TestCertificateAuthoritySource(t *testing.T) {
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca, err := NewRootCertificateAuthority(caKey, nil, NewMemoryCertificateStore())
+	if err != nil {
+		t.Fatalf("Error creating authority: %s", err)
+	}
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	manager, err := NewCertificateManager(key, CertificateAuthoritySource(ca, nil))
+	if err != nil {
+		t.Fatalf("Error creating certificate manager: %s", err)
+	}
+	tlsConfig := &tls.Config{}
+	manager.ConfigureClientTLS(tlsConfig)
+	cert, err := tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil {
+		t.Fatalf("Error getting client certificate: %s", err)
+	}
+	if len(cert.Certificate) != 2 {
+		t.Fatalf("Expected leaf and CA certificates, got %d", len(cert.Certificate))
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{Roots: ca.CertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatalf("Error verifying client certificate: %s", err)
+	}
+}
@@ -0,0 +1,280 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDuplicateSerial is used when a certificate is issued with a serial
+	// number already used by the authority.
+	ErrDuplicateSerial = errors.New("duplicate certificate serial number")
+
+	// ErrUnknownSerial is used when no certificate has been issued with a
+	// serial number.
+	ErrUnknownSerial = errors.New("unknown certificate serial number")
+
+	// ErrAlreadyRevoked is used when revoking a certificate twice.
+	ErrAlreadyRevoked = errors.New("certificate already revoked")
+)
+
+// CertificateStore records the certificates issued by a
+// CertificateAuthority and their revocation. Implementations must be safe
+// for concurrent use.
+type CertificateStore interface {
+	// Add records a newly issued certificate, failing with
+	// ErrDuplicateSerial if its serial number is already recorded.
+	Add(cert *x509.Certificate) error
+	// Get returns the certificate with the given serial number or
+	// ErrUnknownSerial.
+	Get(serial *big.Int) (*x509.Certificate, error)
+	// Revoke records the revocation of the certificate with the given
+	// serial number at the given time.
+	Revoke(serial *big.Int, at time.Time) error
+	// Revoked returns the revoked certificates, ordered by serial number.
+	Revoked() ([]x509.RevocationListEntry, error)
+	// NextCRLNumber returns a new, increasing revocation list number.
+	NextCRLNumber() (*big.Int, error)
+}
+
+// MemoryCertificateStore is a CertificateStore keeping its records in
+// memory.
+type MemoryCertificateStore struct {
+	lock      sync.RWMutex
+	certs     map[string]*x509.Certificate
+	revoked   map[string]time.Time
+	crlNumber int64
+}
+
+// NewMemoryCertificateStore returns an empty in-memory store.
+func NewMemoryCertificateStore() *MemoryCertificateStore {
+	return &MemoryCertificateStore{
+		certs:   make(map[string]*x509.Certificate),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Add records a newly issued certificate.
+func (s *MemoryCertificateStore) Add(cert *x509.Certificate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	serial := cert.SerialNumber.String()
+	if _, ok := s.certs[serial]; ok {
+		return ErrDuplicateSerial
+	}
+	s.certs[serial] = cert
+	return nil
+}
+
+// Get returns the certificate with the given serial number.
+func (s *MemoryCertificateStore) Get(serial *big.Int) (*x509.Certificate, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	cert, ok := s.certs[serial.String()]
+	if !ok {
+		return nil, ErrUnknownSerial
+	}
+	return cert, nil
+}
+
+// Revoke records the revocation of a certificate.
+func (s *MemoryCertificateStore) Revoke(serial *big.Int, at time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := serial.String()
+	if _, ok := s.certs[key]; !ok {
+		return ErrUnknownSerial
+	}
+	if _, ok := s.revoked[key]; ok {
+		return ErrAlreadyRevoked
+	}
+	s.revoked[key] = at
+	return nil
+}
+
+// Revoked returns the revoked certificates.
+func (s *MemoryCertificateStore) Revoked() ([]x509.RevocationListEntry, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	entries := make([]x509.RevocationListEntry, 0, len(s.revoked))
+	for key, at := range s.revoked {
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   s.certs[key].SerialNumber,
+			RevocationTime: at,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SerialNumber.Cmp(entries[j].SerialNumber) < 0
+	})
+	return entries, nil
+}
+
+// NextCRLNumber returns a new revocation list number.
+func (s *MemoryCertificateStore) NextCRLNumber() (*big.Int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.crlNumber++
+	return big.NewInt(s.crlNumber), nil
+}
+
+// fileCertificateRecord is the serialized form of an issued certificate.
+type fileCertificateRecord struct {
+	Certificate []byte     `json:"certificate"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+}
+
+type fileCertificateStoreContent struct {
+	Certificates []fileCertificateRecord `json:"certificates"`
+	CRLNumber    int64                   `json:"crlNumber"`
+}
+
+// FileCertificateStore is a CertificateStore persisting its records to a
+// JSON file, which is rewritten after every change. Changes which cannot be
+// written are undone, so that the store never records more than its file.
+type FileCertificateStore struct {
+	filename string
+
+	// fileLock serializes changes and their writes to the file.
+	fileLock sync.Mutex
+	memory   *MemoryCertificateStore
+}
+
+// NewFileCertificateStore returns a store backed by the given file,
+// loading its records if the file exists.
+func NewFileCertificateStore(filename string) (*FileCertificateStore, error) {
+	s := &FileCertificateStore{
+		filename: filename,
+		memory:   NewMemoryCertificateStore(),
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var stored fileCertificateStoreContent
+	if err := json.Unmarshal(content, &stored); err != nil {
+		return nil, err
+	}
+	for _, record := range stored.Certificates {
+		cert, err := x509.ParseCertificate(record.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.memory.Add(cert); err != nil {
+			return nil, err
+		}
+		if record.RevokedAt != nil {
+			s.memory.revoked[cert.SerialNumber.String()] = *record.RevokedAt
+		}
+	}
+	s.memory.crlNumber = stored.CRLNumber
+
+	return s, nil
+}
+
+// save writes the records to the file, replacing it atomically.
+func (s *FileCertificateStore) save() error {
+	s.memory.lock.RLock()
+	stored := fileCertificateStoreContent{
+		Certificates: make([]fileCertificateRecord, 0, len(s.memory.certs)),
+		CRLNumber:    s.memory.crlNumber,
+	}
+	for key, cert := range s.memory.certs {
+		record := fileCertificateRecord{Certificate: cert.Raw}
+		if at, ok := s.memory.revoked[key]; ok {
+			record.RevokedAt = &at
+		}
+		stored.Certificates = append(stored.Certificates, record)
+	}
+	s.memory.lock.RUnlock()
+
+	content, err := json.MarshalIndent(stored, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.filename), filepath.Base(s.filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename)
+}
+
+// Add records a newly issued certificate.
+func (s *FileCertificateStore) Add(cert *x509.Certificate) error {
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	if err := s.memory.Add(cert); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.memory.lock.Lock()
+		delete(s.memory.certs, cert.SerialNumber.String())
+		s.memory.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Get returns the certificate with the given serial number.
+func (s *FileCertificateStore) Get(serial *big.Int) (*x509.Certificate, error) {
+	return s.memory.Get(serial)
+}
+
+// Revoke records the revocation of a certificate.
+func (s *FileCertificateStore) Revoke(serial *big.Int, at time.Time) error {
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	if err := s.memory.Revoke(serial, at); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.memory.lock.Lock()
+		delete(s.memory.revoked, serial.String())
+		s.memory.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Revoked returns the revoked certificates.
+func (s *FileCertificateStore) Revoked() ([]x509.RevocationListEntry, error) {
+	return s.memory.Revoked()
+}
+
+// NextCRLNumber returns a new revocation list number.
+func (s *FileCertificateStore) NextCRLNumber() (*big.Int, error) {
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	number, err := s.memory.NextCRLNumber()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(); err != nil {
+		s.memory.lock.Lock()
+		s.memory.crlNumber--
+		s.memory.lock.Unlock()
+		return nil, err
+	}
+	return number, nil
+}
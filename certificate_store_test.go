@@ -0,0 +1,106 @@
+package libtrust
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCertificateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libtrust-ca")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "issued.json")
+
+	store, err := NewFileCertificateStore(filename)
+	if err != nil {
+		t.Fatalf("Error creating store: %s", err)
+	}
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca, err := NewRootCertificateAuthority(key, nil, store)
+	if err != nil {
+		t.Fatalf("Error creating authority: %s", err)
+	}
+	issued := make([]*big.Int, 3)
+	for i := range issued {
+		cert, err := ca.IssueLeaf(key.PublicKey(), nil)
+		if err != nil {
+			t.Fatalf("Error issuing certificate: %s", err)
+		}
+		issued[i] = cert.SerialNumber
+	}
+	revokedAt := time.Date(2014, 8, 26, 12, 0, 0, 0, time.UTC)
+	if err := store.Revoke(issued[1], revokedAt); err != nil {
+		t.Fatalf("Error revoking certificate: %s", err)
+	}
+	if _, err := store.NextCRLNumber(); err != nil {
+		t.Fatalf("Error getting revocation list number: %s", err)
+	}
+
+	reloaded, err := NewFileCertificateStore(filename)
+	if err != nil {
+		t.Fatalf("Error reloading store: %s", err)
+	}
+	for _, serial := range issued {
+		cert, err := reloaded.Get(serial)
+		if err != nil {
+			t.Fatalf("Error getting certificate %s: %s", serial, err)
+		}
+		if err := reloaded.Add(cert); err != ErrDuplicateSerial {
+			t.Fatalf("Expected duplicate serial error, got %v", err)
+		}
+	}
+	if _, err := reloaded.Get(big.NewInt(1)); err != ErrUnknownSerial {
+		t.Fatalf("Expected unknown serial error, got %v", err)
+	}
+	revoked, err := reloaded.Revoked()
+	if err != nil {
+		t.Fatalf("Error listing revoked certificates: %s", err)
+	}
+	if len(revoked) != 1 || revoked[0].SerialNumber.Cmp(issued[1]) != 0 || !revoked[0].RevocationTime.Equal(revokedAt) {
+		t.Fatalf("Unexpected revoked certificates %v", revoked)
+	}
+	number, err := reloaded.NextCRLNumber()
+	if err != nil {
+		t.Fatalf("Error getting revocation list number: %s", err)
+	}
+	if number.Int64() != 2 {
+		t.Fatalf("Expected revocation list number 2, got %s", number)
+	}
+
+	// Changes which cannot be saved must not be recorded.
+	reloaded.filename = filepath.Join(dir, "missing", "issued.json")
+	now := time.Now()
+	cert := generateTestCert(t, key.PublicKey(), key, nil, now, now.Add(time.Hour), false)
+	if err := reloaded.Add(cert); err == nil {
+		t.Fatalf("Expected error adding certificate to unwritable store")
+	}
+	if _, err := reloaded.Get(cert.SerialNumber); err != ErrUnknownSerial {
+		t.Fatalf("Expected unknown serial error after failed add, got %v", err)
+	}
+	if err := reloaded.Revoke(issued[0], revokedAt); err == nil {
+		t.Fatalf("Expected error revoking certificate in unwritable store")
+	}
+	if revoked, err := reloaded.Revoked(); err != nil || len(revoked) != 1 {
+		t.Fatalf("Unexpected revoked certificates after failed revocation %v: %v", revoked, err)
+	}
+	if _, err := reloaded.NextCRLNumber(); err == nil {
+		t.Fatalf("Expected error getting revocation list number from unwritable store")
+	}
+	reloaded.filename = filename
+	number, err = reloaded.NextCRLNumber()
+	if err != nil {
+		t.Fatalf("Error getting revocation list number: %s", err)
+	}
+	if number.Int64() != 3 {
+		t.Fatalf("Expected revocation list number 3, got %s", number)
+	}
+}
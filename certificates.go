@@ -2,29 +2,81 @@ package libtrust
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/pem"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
 	"net"
+	"net/url"
+	"os"
 	"time"
 )
 
+// Default validity of generated certificates, from the past week to 10
+// years from now.
+const (
+	defaultCertBackdate = time.Hour * 24 * 7
+	defaultCertValidity = time.Hour * 24 * 365 * 10
+)
+
+// serialNumberLimit bounds random serial numbers to 128 bits.
+var serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// CertificateOptions customize the certificates generated by libtrust. The
+// zero value produces the same certificates as the functions without
+// options, with a random serial number.
+type CertificateOptions struct {
+	// SerialNumber is the serial number of the certificate. If nil, a
+	// random 128-bit serial number is used.
+	SerialNumber *big.Int
+	// NotBefore is the start of the validity period. If zero, it is one
+	// week before the current time.
+	NotBefore time.Time
+	// NotAfter is the end of the validity period. If zero, it is ten years
+	// after the current time.
+	NotAfter time.Time
+	// Subject is the subject name of the certificate. If its common name is
+	// empty, the ID of the certified key is used.
+	Subject pkix.Name
+	// URIs are URI subject alternative names.
+	URIs []*url.URL
+	// EmailAddresses are email subject alternative names.
+	EmailAddresses []string
+	// ExtraExtensions are added to the certificate, overriding any
+	// extension with the same OID libtrust would generate.
+	ExtraExtensions []pkix.Extension
+	// MaxPathLen limits the number of intermediate certificates below a CA
+	// certificate, as for x509.Certificate. It is ignored for other
+	// certificates. A value of zero means no limit unless MaxPathLenZero
+	// is set; a negative value means no limit.
+	MaxPathLen int
+	// MaxPathLenZero makes a MaxPathLen of zero a limit, so that the CA
+	// may only issue leaf certificates.
+	MaxPathLenZero bool
+}
+
 type certTemplateInfo struct {
+	key         PublicKey
 	commonName  string
 	domains     []string
 	ipAddresses []net.IP
 	isCA        bool
 	clientAuth  bool
 	serverAuth  bool
+	options     *CertificateOptions
+	// omitKeyURIs leaves out the URIs identifying the key, which cannot be
+	// verified below name constraints.
+	omitKeyURIs bool
 }
 
-func generateCertTemplate(info *certTemplateInfo) *x509.Certificate {
+func generateCertTemplate(info *certTemplateInfo) (*x509.Certificate, error) {
 	// Generate a certificate template which is valid from the past week to
-	// 10 years from now. The usage of the certificate depends on the
-	// specified fields in the given certTempInfo object.
+	// 10 years from now unless options say otherwise. The usage of the
+	// certificate depends on the specified fields in the given
+	// certTempInfo object.
 	var (
 		keyUsage    x509.KeyUsage
 		extKeyUsage []x509.ExtKeyUsage
@@ -42,25 +94,86 @@ func generateCertTemplate(info *certTemplateInfo) *x509.Certificate {
 		extKeyUsage = append(extKeyUsage, x509.ExtKeyUsageServerAuth)
 	}
 
-	return &x509.Certificate{
-		SerialNumber: big.NewInt(0),
-		Subject: pkix.Name{
-			CommonName: info.commonName,
-		},
-		NotBefore:             time.Now().Add(-time.Hour * 24 * 7),
-		NotAfter:              time.Now().Add(time.Hour * 24 * 365 * 10),
+	opts := info.options
+	if opts == nil {
+		opts = &CertificateOptions{}
+	}
+
+	serialNumber := opts.SerialNumber
+	if serialNumber == nil {
+		var err error
+		serialNumber, err = rand.Int(rand.Reader, serialNumberLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate serial number: %s", err)
+		}
+	} else if serialNumber.Sign() < 0 {
+		return nil, errors.New("serial number must not be negative")
+	}
+
+	now := time.Now()
+	notBefore, notAfter := opts.NotBefore, opts.NotAfter
+	if notBefore.IsZero() {
+		notBefore = now.Add(-defaultCertBackdate)
+	}
+	if notAfter.IsZero() {
+		notAfter = now.Add(defaultCertValidity)
+	}
+	if !notAfter.After(notBefore) {
+		return nil, fmt.Errorf("certificate validity ends at %s, before it starts at %s", notAfter.Format(time.RFC3339), notBefore.Format(time.RFC3339))
+	}
+
+	subject := opts.Subject
+	if subject.CommonName == "" {
+		subject.CommonName = info.commonName
+	}
+
+	// Identify the certified key by subject alternative names and a subject
+	// key identifier which encodes to its key ID. The key ID and thumbprint
+	// URIs have no host, so Go rejects them below any name constraint: key
+	// hierarchies omit them there.
+	var uris []*url.URL
+	if !info.omitKeyURIs {
+		var err error
+		uris, err = keyIdentityURIs(info.key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	uris = append(uris, opts.URIs...)
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
 		DNSNames:              info.domains,
 		IPAddresses:           info.ipAddresses,
+		URIs:                  uris,
+		EmailAddresses:        opts.EmailAddresses,
+		ExtraExtensions:       opts.ExtraExtensions,
 		IsCA:                  info.isCA,
 		KeyUsage:              keyUsage,
 		ExtKeyUsage:           extKeyUsage,
 		BasicConstraintsValid: info.isCA,
+		SubjectKeyId:          keyIDHash(info.key),
 	}
+	if info.isCA {
+		template.MaxPathLen = opts.MaxPathLen
+		template.MaxPathLenZero = opts.MaxPathLenZero
+	}
+
+	return template, nil
 }
 
 func generateCert(pub PublicKey, priv PrivateKey, subInfo, issInfo *certTemplateInfo) (cert *x509.Certificate, err error) {
-	pubCertTemplate := generateCertTemplate(subInfo)
-	privCertTemplate := generateCertTemplate(issInfo)
+	pubCertTemplate, err := generateCertTemplate(subInfo)
+	if err != nil {
+		return nil, err
+	}
+	privCertTemplate, err := generateCertTemplate(issInfo)
+	if err != nil {
+		return nil, err
+	}
 
 	certDER, err := x509.CreateCertificate(
 		rand.Reader, pubCertTemplate, privCertTemplate,
@@ -82,11 +195,20 @@ func generateCert(pub PublicKey, priv PrivateKey, subInfo, issInfo *certTemplate
 // given key which is to be used for TLS servers with the given domains and
 // IP addresses.
 func GenerateSelfSignedServerCert(key PrivateKey, domains []string, ipAddresses []net.IP) (*x509.Certificate, error) {
+	return GenerateSelfSignedServerCertWithOptions(key, domains, ipAddresses, nil)
+}
+
+// GenerateSelfSignedServerCertWithOptions creates a self-signed server
+// certificate like GenerateSelfSignedServerCert, customized by the given
+// options.
+func GenerateSelfSignedServerCertWithOptions(key PrivateKey, domains []string, ipAddresses []net.IP, opts *CertificateOptions) (*x509.Certificate, error) {
 	info := &certTemplateInfo{
+		key:         key.PublicKey(),
 		commonName:  key.KeyID(),
 		domains:     domains,
 		ipAddresses: ipAddresses,
 		serverAuth:  true,
+		options:     opts,
 	}
 
 	return generateCert(key.PublicKey(), key, info, info)
@@ -95,9 +217,18 @@ func GenerateSelfSignedServerCert(key PrivateKey, domains []string, ipAddresses
 // GenerateSelfSignedClientCert creates a self-signed certificate for the
 // given key which is to be used for TLS clients.
 func GenerateSelfSignedClientCert(key PrivateKey) (*x509.Certificate, error) {
+	return GenerateSelfSignedClientCertWithOptions(key, nil)
+}
+
+// GenerateSelfSignedClientCertWithOptions creates a self-signed client
+// certificate like GenerateSelfSignedClientCert, customized by the given
+// options.
+func GenerateSelfSignedClientCertWithOptions(key PrivateKey, opts *CertificateOptions) (*x509.Certificate, error) {
 	info := &certTemplateInfo{
+		key:        key.PublicKey(),
 		commonName: key.KeyID(),
 		clientAuth: true,
+		options:    opts,
 	}
 
 	return generateCert(key.PublicKey(), key, info, info)
@@ -106,11 +237,21 @@ func GenerateSelfSignedClientCert(key PrivateKey) (*x509.Certificate, error) {
 // GenerateCACert creates a certificate which can be used as a trusted
 // certificate authority.
 func GenerateCACert(signer PrivateKey, trustedKey PublicKey) (*x509.Certificate, error) {
+	return GenerateCACertWithOptions(signer, trustedKey, nil)
+}
+
+// GenerateCACertWithOptions creates a CA certificate like GenerateCACert,
+// customized by the given options. The options apply to the certificate of
+// the trusted key; the issuer is still named by the ID of the signer.
+func GenerateCACertWithOptions(signer PrivateKey, trustedKey PublicKey, opts *CertificateOptions) (*x509.Certificate, error) {
 	subjectInfo := &certTemplateInfo{
+		key:        trustedKey,
 		commonName: trustedKey.KeyID(),
 		isCA:       true,
+		options:    opts,
 	}
 	issuerInfo := &certTemplateInfo{
+		key:        signer.PublicKey(),
 		commonName: signer.KeyID(),
 	}
 
@@ -137,27 +278,19 @@ func GenerateCACertPool(signer PrivateKey, trustedKeys []PublicKey) (*x509.CertP
 
 // LoadCertificateBundle loads certificates from the given file.  The file should be pem encoded
 // containing one or more certificates.  The expected pem type is "CERTIFICATE".
+// DER encoded certificates and PKCS#7 bundles are also accepted. Use
+// ReadCertificateBundle to read files containing other blocks.
 func LoadCertificateBundle(filename string) ([]*x509.Certificate, error) {
-	b, err := ioutil.ReadFile(filename)
+	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
-	certificates := []*x509.Certificate{}
-	var block *pem.Block
-	block, b = pem.Decode(b)
-	for ; block != nil; block, b = pem.Decode(b) {
-		if block.Type == "CERTIFICATE" {
-			cert, err := x509.ParseCertificate(block.Bytes)
-			if err != nil {
-				return nil, err
-			}
-			certificates = append(certificates, cert)
-		} else {
-			return nil, fmt.Errorf("invalid pem block type: %s", block.Type)
-		}
+	defer f.Close()
+	bundle, err := ReadCertificateBundle(f, nil)
+	if err != nil {
+		return nil, err
 	}
-
-	return certificates, nil
+	return bundle.Certificates, nil
 }
 
 // LoadCertificatePool loads a CA pool from the given file.  The file should be pem encoded
@@ -173,3 +306,10 @@ func LoadCertificatePool(filename string) (*x509.CertPool, error) {
 	}
 	return pool, nil
 }
+
+// CertificateFingerprint returns the hex encoded SHA-256 hash of the DER
+// encoding of the certificate.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
@@ -1,12 +1,17 @@
 package libtrust
 
 import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"io/ioutil"
+	"math/big"
 	"net"
+	"net/url"
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 func TestGenerateCertificates(t *testing.T) {
@@ -109,3 +114,91 @@ func TestLoadCertificates(t *testing.T) {
 		t.Fatalf("Invalid certificate pool")
 	}
 }
+
+func TestCertificateOptions(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Default certificates get distinct random serial numbers.
+	cert1, err := GenerateSelfSignedClientCert(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert2, err := GenerateSelfSignedClientCert(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert1.SerialNumber.Sign() == 0 || cert1.SerialNumber.Cmp(cert2.SerialNumber) == 0 {
+		t.Fatalf("Expected distinct random serial numbers, got %s and %s", cert1.SerialNumber, cert2.SerialNumber)
+	}
+	if cert1.Subject.CommonName != key.KeyID() {
+		t.Fatalf("Unexpected common name %q", cert1.Subject.CommonName)
+	}
+
+	notBefore := time.Date(2014, 8, 26, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(24 * time.Hour)
+	uri, _ := url.Parse("spiffe://example.com/service")
+	extension := pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: []byte{0x05, 0x00}}
+	opts := &CertificateOptions{
+		SerialNumber:    big.NewInt(42),
+		NotBefore:       notBefore,
+		NotAfter:        notAfter,
+		Subject:         pkix.Name{Organization: []string{"Docker"}, CommonName: "server"},
+		URIs:            []*url.URL{uri},
+		EmailAddresses:  []string{"admin@example.com"},
+		ExtraExtensions: []pkix.Extension{extension},
+		MaxPathLen:      1,
+	}
+	cert, err := GenerateSelfSignedServerCertWithOptions(key, []string{"localhost"}, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.SerialNumber.Int64() != 42 || !cert.NotBefore.Equal(notBefore) || !cert.NotAfter.Equal(notAfter) {
+		t.Fatalf("Unexpected serial number or validity: %s %s %s", cert.SerialNumber, cert.NotBefore, cert.NotAfter)
+	}
+	if cert.Subject.CommonName != "server" || len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != "Docker" {
+		t.Fatalf("Unexpected subject %s", cert.Subject)
+	}
+	if len(cert.URIs) != 3 || cert.URIs[2].String() != uri.String() {
+		t.Fatalf("Unexpected URIs %v", cert.URIs)
+	}
+	if len(cert.EmailAddresses) != 1 || cert.EmailAddresses[0] != "admin@example.com" {
+		t.Fatalf("Unexpected email addresses %v", cert.EmailAddresses)
+	}
+	found := false
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(extension.Id) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Missing custom extension")
+	}
+	if cert.MaxPathLen > 0 {
+		t.Fatalf("Path length constraint set on a leaf certificate")
+	}
+
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := GenerateCACertWithOptions(caKey, key.PublicKey(), &CertificateOptions{MaxPathLenZero: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caCert.IsCA || caCert.MaxPathLen != 0 || !caCert.MaxPathLenZero {
+		t.Fatalf("Expected CA certificate with zero path length, got %d", caCert.MaxPathLen)
+	}
+	if caCert.Issuer.CommonName != caKey.KeyID() {
+		t.Fatalf("Unexpected issuer %s", caCert.Issuer)
+	}
+
+	if _, err := GenerateSelfSignedClientCertWithOptions(key, &CertificateOptions{NotBefore: notAfter, NotAfter: notBefore}); err == nil {
+		t.Fatalf("Expected error for inverted validity")
+	}
+	if _, err := GenerateSelfSignedClientCertWithOptions(key, &CertificateOptions{SerialNumber: big.NewInt(-1)}); err == nil {
+		t.Fatalf("Expected error for negative serial number")
+	}
+}
@@ -0,0 +1,113 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChainProblem categorizes why a chain was rejected for signing.
+type ChainProblem int
+
+const (
+	// ChainEmpty is used when no certificate is given.
+	ChainEmpty ChainProblem = iota + 1
+	// ChainKeyMismatch is used when the leaf certificate is not for the
+	// public key of the signing key.
+	ChainKeyMismatch
+	// ChainOrder is used when a certificate was not issued by the
+	// certificate following it in the chain.
+	ChainOrder
+	// ChainValidity is used when a certificate has expired or is not yet
+	// valid.
+	ChainValidity
+	// ChainKeyUsage is used when the leaf certificate does not allow the
+	// digitalSignature key usage.
+	ChainKeyUsage
+)
+
+// String returns a short description of the problem.
+func (p ChainProblem) String() string {
+	switch p {
+	case ChainEmpty:
+		return "empty chain"
+	case ChainKeyMismatch:
+		return "leaf key mismatch"
+	case ChainOrder:
+		return "chain out of order"
+	case ChainValidity:
+		return "certificate not valid"
+	case ChainKeyUsage:
+		return "key usage not allowed"
+	default:
+		return fmt.Sprintf("ChainProblem(%d)", int(p))
+	}
+}
+
+// ChainError is returned when signing with a chain which would produce
+// signatures failing verification.
+type ChainError struct {
+	// Index is the position in the chain of the offending certificate.
+	Index int
+	// Problem is the category of the problem.
+	Problem ChainProblem
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("invalid signing chain: certificate %d: %s: %s", e.Index, e.Problem, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// checkSigningChain checks that the chain can be used to sign with the
+// given key at the given time: its leaf is for the key and allows digital
+// signatures, each certificate is issued by the next one and all of them
+// are valid.
+func checkSigningChain(key PrivateKey, chain []*x509.Certificate, now time.Time) error {
+	if err := checkKeyChain(key, chain, now); err != nil {
+		return err
+	}
+	if leaf := chain[0]; leaf.KeyUsage != 0 && leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return &ChainError{Problem: ChainKeyUsage, Err: errors.New("leaf certificate does not allow digitalSignature")}
+	}
+	return nil
+}
+
+// checkKeyChain checks that the leaf of the chain is for the given key,
+// each certificate is issued by the next one and all of them are valid at
+// the given time, whatever the usage of the leaf.
+func checkKeyChain(key PrivateKey, chain []*x509.Certificate, now time.Time) error {
+	if len(chain) == 0 {
+		return &ChainError{Problem: ChainEmpty, Err: errors.New("no certificates")}
+	}
+
+	leafKey, err := FromCryptoPublicKey(chain[0].PublicKey)
+	if err != nil {
+		return &ChainError{Problem: ChainKeyMismatch, Err: err}
+	}
+	if leafKey.KeyID() != key.KeyID() {
+		return &ChainError{Problem: ChainKeyMismatch, Err: fmt.Errorf("leaf certificate is for key %s, not %s", leafKey.KeyID(), key.KeyID())}
+	}
+
+	for i, cert := range chain {
+		if now.Before(cert.NotBefore) {
+			return &ChainError{Index: i, Problem: ChainValidity, Err: fmt.Errorf("not valid before %s", cert.NotBefore.Format(time.RFC3339))}
+		}
+		if now.After(cert.NotAfter) {
+			return &ChainError{Index: i, Problem: ChainValidity, Err: fmt.Errorf("expired at %s", cert.NotAfter.Format(time.RFC3339))}
+		}
+		if i > 0 {
+			if err := chain[i-1].CheckSignatureFrom(cert); err != nil {
+				return &ChainError{Index: i - 1, Problem: ChainOrder, Err: fmt.Errorf("not issued by certificate %d: %s", i, err)}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust/testutil"
+)
+
+func expectChainError(t *testing.T, err error, index int, problem ChainProblem) {
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("Expected chain error, got %v", err)
+	}
+	if chainErr.Index != index || chainErr.Problem != problem {
+		t.Fatalf("Unexpected chain error: index %d, problem %s", chainErr.Index, chainErr.Problem)
+	}
+}
+
+func TestSignWithChainValidation(t *testing.T) {
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating ca: %s", err)
+	}
+	trustKey, chain := generateTrustChain(t, caKey, ca)
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+
+	expectChainError(t, js.SignWithChain(trustKey, nil), 0, ChainEmpty)
+	expectChainError(t, js.SignWithChain(otherKey, chain), 0, ChainKeyMismatch)
+
+	reordered := append([]*x509.Certificate{chain[0], chain[2], chain[1]}, chain[3:]...)
+	expectChainError(t, js.SignWithChain(trustKey, reordered), 0, ChainOrder)
+
+	now := time.Now()
+	expired := generateTestCert(t, trustKey.PublicKey(), caKey, ca, now.Add(-time.Hour), now.Add(-time.Minute), false)
+	expectChainError(t, js.SignWithChain(trustKey, []*x509.Certificate{expired, ca}), 0, ChainValidity)
+
+	notYetValid := generateTestCert(t, trustKey.PublicKey(), caKey, ca, now.Add(time.Hour), now.Add(2*time.Hour), false)
+	expectChainError(t, js.SignWithChain(trustKey, []*x509.Certificate{notYetValid}), 0, ChainValidity)
+
+	certSignOnly := generateTestCert(t, trustKey.PublicKey(), caKey, ca, now.Add(-time.Hour), now.Add(time.Hour), true)
+	certSignOnly.KeyUsage = x509.KeyUsageCertSign
+	expectChainError(t, js.SignWithChain(trustKey, []*x509.Certificate{certSignOnly}), 0, ChainKeyUsage)
+
+	if err := js.CountersignWithChain(otherKey, chain, trustKey.KeyID()); err == nil {
+		t.Fatalf("Expected error countersigning with another key's chain")
+	}
+	if len(js.signatures) != 0 {
+		t.Fatalf("Unexpected signatures added by failed signing: %d", len(js.signatures))
+	}
+
+	if err := js.SignWithChain(trustKey, chain); err != nil {
+		t.Fatalf("Error signing with valid chain: %s", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	if _, err := js.VerifyChains(pool); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+}
@@ -0,0 +1,105 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMissingChain is used when verification requires an x509 chain and a
+// signature has none.
+var ErrMissingChain = errors.New("signature has no x509 chain")
+
+// anyPolicy is the OID of the special anyPolicy certificate policy.
+var anyPolicy = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+
+// ChainVerifyOptions configure the verification of the x509 chains of
+// signatures by VerifyChainsWithOptions.
+type ChainVerifyOptions struct {
+	// Roots are the trusted certificate authorities. If nil, the system
+	// roots are used.
+	Roots *x509.CertPool
+	// CurrentTime is the time at which the chains are verified. If zero,
+	// the current time is used.
+	CurrentTime time.Time
+	// KeyUsages are the acceptable extended key usages, at least one of
+	// which every certificate of a chain must allow. If empty, server
+	// authentication is required as by VerifyChains. Use
+	// x509.ExtKeyUsageAny to accept any usage.
+	KeyUsages []x509.ExtKeyUsage
+	// KeyUsageOIDs are extended key usages unknown to the x509 package, any
+	// of which the leaf certificate must list when not empty.
+	KeyUsageOIDs []asn1.ObjectIdentifier
+	// DNSName, if not empty, is a name the leaf certificate must be valid
+	// for.
+	DNSName string
+	// URI, if not empty, is a URI the leaf certificate must list as a
+	// subject alternative name.
+	URI string
+	// Policies are certificate policies, any of which the leaf certificate
+	// must assert when not empty. The anyPolicy policy matches all of them.
+	Policies []asn1.ObjectIdentifier
+	// RequireChain makes a signature without an x509 chain fail
+	// verification rather than being skipped.
+	RequireChain bool
+	// Revocation, if not nil, checks the verified chains for revoked
+	// certificates.
+	Revocation RevocationChecker
+	// RequireOCSP makes a signature fail verification unless it carries a
+	// current stapled OCSP response about its leaf certificate. Stapled
+	// responses are checked even if not required.
+	RequireOCSP bool
+}
+
+// checkLeaf checks the requirements on the leaf certificate which are not
+// enforced by x509 verification.
+func (opts *ChainVerifyOptions) checkLeaf(leaf *x509.Certificate) error {
+	if len(opts.KeyUsageOIDs) > 0 && !containsOID(leaf.UnknownExtKeyUsage, opts.KeyUsageOIDs) {
+		return errors.New("leaf certificate does not allow the required extended key usage")
+	}
+	if opts.URI != "" {
+		found := false
+		for _, uri := range leaf.URIs {
+			if uri.String() == opts.URI {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("leaf certificate is not valid for %s", opts.URI)
+		}
+	}
+	if len(opts.Policies) > 0 && !containsOID(leaf.PolicyIdentifiers, opts.Policies) && !containsOID(leaf.PolicyIdentifiers, []asn1.ObjectIdentifier{anyPolicy}) {
+		return errors.New("leaf certificate does not assert a required policy")
+	}
+	return nil
+}
+
+// containsOID returns whether any of the wanted OIDs is in oids.
+func containsOID(oids, wanted []asn1.ObjectIdentifier) bool {
+	for _, oid := range oids {
+		for _, w := range wanted {
+			if oid.Equal(w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyChainsWithOptions verifies all the signatures and the chains
+// associated with each signature according to the given options and
+// returns the list of verified chains.
+func (js *JSONSignature) VerifyChainsWithOptions(opts ChainVerifyOptions) ([][]*x509.Certificate, error) {
+	chains := make([][]*x509.Certificate, 0, len(js.signatures))
+	for i := range js.signatures {
+		verifiedChains, err := js.verifySignatureChains(&js.signatures[i], &opts)
+		if err != nil {
+			return nil, js.signatureError(i, err)
+		}
+		chains = append(chains, verifiedChains...)
+	}
+	return chains, nil
+}
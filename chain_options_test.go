@@ -0,0 +1,132 @@
+package libtrust
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifyChainsWithOptions(t *testing.T) {
+	now := time.Now()
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca := generateTestCert(t, caKey.PublicKey(), caKey, nil, now.Add(-48*time.Hour), now.Add(48*time.Hour), true)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	signerKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	buildOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+	policyOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2}
+	uri, err := url.Parse("spiffe://example.com/builder")
+	if err != nil {
+		t.Fatalf("Error parsing URI: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(2),
+		Subject:            pkix.Name{CommonName: "builder"},
+		NotBefore:          now.Add(-24 * time.Hour),
+		NotAfter:           now.Add(24 * time.Hour),
+		KeyUsage:           x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{buildOID},
+		DNSNames:           []string{"builder.example.com"},
+		URIs:               []*url.URL{uri},
+		PolicyIdentifiers:  []asn1.ObjectIdentifier{policyOID},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, signerKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	leaf, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err)
+	}
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(signerKey, []*x509.Certificate{leaf}); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+
+	// The default server authentication usage is not allowed.
+	if _, err := js.VerifyChains(pool); err == nil {
+		t.Fatalf("Expected code signing certificate to fail default verification")
+	}
+
+	valid := ChainVerifyOptions{
+		Roots:        pool,
+		KeyUsages:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		KeyUsageOIDs: []asn1.ObjectIdentifier{buildOID},
+		DNSName:      "builder.example.com",
+		URI:          "spiffe://example.com/builder",
+		Policies:     []asn1.ObjectIdentifier{policyOID},
+		RequireChain: true,
+	}
+	chains, err := js.VerifyChainsWithOptions(valid)
+	if err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+	if len(chains) != 1 || !chains[0][0].Equal(leaf) {
+		t.Fatalf("Unexpected verified chains: %v", chains)
+	}
+
+	for name, modify := range map[string]func(*ChainVerifyOptions){
+		"time":      func(o *ChainVerifyOptions) { o.CurrentTime = now.Add(36 * time.Hour) },
+		"usage":     func(o *ChainVerifyOptions) { o.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection} },
+		"usage oid": func(o *ChainVerifyOptions) { o.KeyUsageOIDs = []asn1.ObjectIdentifier{policyOID} },
+		"dns name":  func(o *ChainVerifyOptions) { o.DNSName = "other.example.com" },
+		"uri":       func(o *ChainVerifyOptions) { o.URI = "spiffe://example.com/other" },
+		"policy":    func(o *ChainVerifyOptions) { o.Policies = []asn1.ObjectIdentifier{buildOID} },
+	} {
+		opts := valid
+		modify(&opts)
+		_, err := js.VerifyChainsWithOptions(opts)
+		if err == nil {
+			t.Fatalf("Expected %s requirement to fail verification", name)
+		}
+		failure := FailureInvalidChain
+		if name == "time" {
+			failure = FailureExpiredCertificate
+		}
+		expectSignatureError(t, err, 0, signerKey.KeyID(), failure)
+	}
+
+	// Historical verification accepts an expired certificate.
+	opts := valid
+	opts.CurrentTime = now.Add(-time.Hour)
+	if _, err := js.VerifyChainsWithOptions(opts); err != nil {
+		t.Fatalf("Error verifying chains at an earlier time: %s", err)
+	}
+
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	if err := js.Sign(otherKey); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	opts = valid
+	opts.RequireChain = false
+	if _, err := js.VerifyChainsWithOptions(opts); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+	_, err = js.VerifyChainsWithOptions(valid)
+	index := 0
+	if js.signatures[1].keyID() == otherKey.KeyID() {
+		index = 1
+	}
+	expectSignatureError(t, err, index, otherKey.KeyID(), FailureMissingChain)
+}
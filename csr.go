@@ -0,0 +1,105 @@
+package libtrust
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// CertificateRequestOptions describe the certificate requested by a
+// certificate signing request.
+type CertificateRequestOptions struct {
+	// Subject is the requested subject name. If its common name is empty,
+	// the ID of the key is used.
+	Subject pkix.Name
+	// DNSNames are requested DNS subject alternative names.
+	DNSNames []string
+	// IPAddresses are requested IP address subject alternative names.
+	IPAddresses []net.IP
+	// URIs are requested URI subject alternative names.
+	URIs []*url.URL
+	// EmailAddresses are requested email subject alternative names.
+	EmailAddresses []string
+}
+
+// CertificateRequest is a parsed certificate signing request whose
+// signature has been verified.
+type CertificateRequest struct {
+	// PublicKey is the key to be certified.
+	PublicKey PublicKey
+	// Subject is the requested subject name.
+	Subject pkix.Name
+	// DNSNames are the requested DNS subject alternative names.
+	DNSNames []string
+	// IPAddresses are the requested IP address subject alternative names.
+	IPAddresses []net.IP
+	// URIs are the requested URI subject alternative names.
+	URIs []*url.URL
+	// EmailAddresses are the requested email subject alternative names.
+	EmailAddresses []string
+	// Request is the underlying request, which can be passed to
+	// IssueLeafFromCSR.
+	Request *x509.CertificateRequest
+}
+
+// GenerateCertificateRequest creates a DER encoded certificate signing
+// request for the given key, signed by the key.
+func GenerateCertificateRequest(key PrivateKey, opts *CertificateRequestOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &CertificateRequestOptions{}
+	}
+	subject := opts.Subject
+	if subject.CommonName == "" {
+		subject.CommonName = key.KeyID()
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:        subject,
+		DNSNames:       opts.DNSNames,
+		IPAddresses:    opts.IPAddresses,
+		URIs:           opts.URIs,
+		EmailAddresses: opts.EmailAddresses,
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, key.CryptoPrivateKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate request: %s", err)
+	}
+	return csr, nil
+}
+
+// ParseCertificateRequest parses a PEM or DER encoded certificate signing
+// request and verifies its signature.
+func ParseCertificateRequest(content []byte) (*CertificateRequest, error) {
+	if block, _ := pem.Decode(content); block != nil {
+		if block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST" {
+			return nil, fmt.Errorf("invalid pem block type: %s", block.Type)
+		}
+		content = block.Bytes
+	}
+
+	csr, err := x509.ParseCertificateRequest(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate request: %s", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid certificate request signature: %s", err)
+	}
+	publicKey, err := FromCryptoPublicKey(csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertificateRequest{
+		PublicKey:      publicKey,
+		Subject:        csr.Subject,
+		DNSNames:       csr.DNSNames,
+		IPAddresses:    csr.IPAddresses,
+		URIs:           csr.URIs,
+		EmailAddresses: csr.EmailAddresses,
+		Request:        csr,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package libtrust
+
+import (
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestCertificateRequest(t *testing.T) {
+	ecKeys := generateECTestKeys(t)
+	for _, key := range []PrivateKey{ecKeys[0], rsaKeys[0]} {
+		csr, err := GenerateCertificateRequest(key, nil)
+		if err != nil {
+			t.Fatalf("Error generating certificate request: %s", err)
+		}
+		parsed, err := ParseCertificateRequest(csr)
+		if err != nil {
+			t.Fatalf("Error parsing certificate request: %s", err)
+		}
+		if parsed.PublicKey.KeyID() != key.KeyID() || parsed.Subject.CommonName != key.KeyID() {
+			t.Fatalf("Unexpected request for key %s with subject %s", parsed.PublicKey.KeyID(), parsed.Subject)
+		}
+	}
+
+	key := ecKeys[1]
+	uri, _ := url.Parse("spiffe://example.com/service")
+	csr, err := GenerateCertificateRequest(key, &CertificateRequestOptions{
+		Subject:     pkix.Name{CommonName: "service", Organization: []string{"Docker"}},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		URIs:        []*url.URL{uri},
+	})
+	if err != nil {
+		t.Fatalf("Error generating certificate request: %s", err)
+	}
+	parsed, err := ParseCertificateRequest(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}))
+	if err != nil {
+		t.Fatalf("Error parsing PEM certificate request: %s", err)
+	}
+	if parsed.Subject.CommonName != "service" || len(parsed.DNSNames) != 1 || len(parsed.IPAddresses) != 1 || len(parsed.URIs) != 1 || parsed.URIs[0].String() != uri.String() {
+		t.Fatalf("Unexpected requested attributes: %s %v %v %v", parsed.Subject, parsed.DNSNames, parsed.IPAddresses, parsed.URIs)
+	}
+
+	if _, err := ParseCertificateRequest(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: csr})); err == nil {
+		t.Fatalf("Expected error parsing wrong PEM block type")
+	}
+	csr[len(csr)-1] ^= 0xff
+	if _, err := ParseCertificateRequest(csr); err == nil {
+		t.Fatalf("Expected error parsing tampered certificate request")
+	}
+}
@@ -73,6 +73,22 @@ func (k *ecPublicKey) Verify(data io.Reader, alg string, signature []byte) error
 		return fmt.Errorf("unable to verify signature: EC Public Key with curve %q does not support signature algorithm %q", k.curveName, alg)
 	}
 
+	hasher := k.signatureAlgorithm.HashID().New()
+	_, err := io.Copy(hasher, data)
+	if err != nil {
+		return fmt.Errorf("error reading data to sign: %s", err)
+	}
+
+	return k.verifyDigest(k.signatureAlgorithm, hasher.Sum(nil), signature)
+}
+
+// verifyDigest verifies the signature of an already computed hash of the
+// signed data.
+func (k *ecPublicKey) verifyDigest(sigAlg *signatureAlgorithm, hash, signature []byte) error {
+	if k.signatureAlgorithm != sigAlg {
+		return fmt.Errorf("unable to verify signature: EC Public Key with curve %q does not support signature algorithm %q", k.curveName, sigAlg.HeaderParam())
+	}
+
 	// signature is the concatenation of (r, s), base64Url encoded.
 	sigLength := len(signature)
 	expectedOctetLength := 2 * ((k.Params().BitSize + 7) >> 3)
@@ -84,13 +100,6 @@ func (k *ecPublicKey) Verify(data io.Reader, alg string, signature []byte) error
 	r := new(big.Int).SetBytes(rBytes)
 	s := new(big.Int).SetBytes(sBytes)
 
-	hasher := k.signatureAlgorithm.HashID().New()
-	_, err := io.Copy(hasher, data)
-	if err != nil {
-		return fmt.Errorf("error reading data to sign: %s", err)
-	}
-	hash := hasher.Sum(nil)
-
 	if !ecdsa.Verify(k.PublicKey, hash, r, s) {
 		return errors.New("invalid signature")
 	}
@@ -274,11 +283,32 @@ func (k *ecPrivateKey) Sign(data io.Reader, hashID crypto.Hash) (signature []byt
 	if err != nil {
 		return nil, "", fmt.Errorf("error reading data to sign: %s", err)
 	}
-	hash := hasher.Sum(nil)
+
+	signature, err = k.signDigest(k.signatureAlgorithm, hasher.Sum(nil))
+	if err != nil {
+		return nil, "", err
+	}
+	alg = k.signatureAlgorithm.HeaderParam()
+
+	return
+}
+
+// signatureAlgorithmForHash returns the signature algorithm this key uses
+// when asked to sign with the given hash. EC keys only support the single
+// algorithm determined by their curve.
+func (k *ecPrivateKey) signatureAlgorithmForHash(hashID crypto.Hash) *signatureAlgorithm {
+	return k.signatureAlgorithm
+}
+
+// signDigest signs an already computed hash of the data to sign.
+func (k *ecPrivateKey) signDigest(sigAlg *signatureAlgorithm, hash []byte) ([]byte, error) {
+	if k.signatureAlgorithm != sigAlg {
+		return nil, fmt.Errorf("EC Private Key with curve %q does not support signature algorithm %q", k.curveName, sigAlg.HeaderParam())
+	}
 
 	r, s, err := ecdsa.Sign(rand.Reader, k.PrivateKey, hash)
 	if err != nil {
-		return nil, "", fmt.Errorf("error producing signature: %s", err)
+		return nil, fmt.Errorf("error producing signature: %s", err)
 	}
 	rBytes, sBytes := r.Bytes(), s.Bytes()
 	octetLength := (k.ecPublicKey.Params().BitSize + 7) >> 3
@@ -289,10 +319,7 @@ func (k *ecPrivateKey) Sign(data io.Reader, hashID crypto.Hash) (signature []byt
 	rBuf = append(rBuf, rBytes...)
 	sBuf = append(sBuf, sBytes...)
 
-	signature = append(rBuf, sBuf...)
-	alg = k.signatureAlgorithm.HeaderParam()
-
-	return
+	return append(rBuf, sBuf...), nil
 }
 
 // CryptoPrivateKey returns the internal object which can be used as a
@@ -54,3 +54,22 @@ func rsaPKCS1v15SignatureAlgorithmForHashID(hashID crypto.Hash) *signatureAlgori
 		return rs256
 	}
 }
+
+func signatureAlgorithmByName(alg string) (*signatureAlgorithm, error) {
+	switch {
+	case alg == "ES256":
+		return es256, nil
+	case alg == "ES384":
+		return es384, nil
+	case alg == "ES512":
+		return es512, nil
+	case alg == "RS256":
+		return rs256, nil
+	case alg == "RS384":
+		return rs384, nil
+	case alg == "RS512":
+		return rs512, nil
+	default:
+		return nil, fmt.Errorf("Digital Signature Algorithm %q not supported", alg)
+	}
+}
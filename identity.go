@@ -0,0 +1,136 @@
+package libtrust
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// KeyIDURIPrefix prefixes the key ID in the URI subject alternative
+	// name of certificates generated by libtrust.
+	KeyIDURIPrefix = "libtrust:key:"
+
+	// jwkThumbprintURIPrefix prefixes a SHA-256 JWK thumbprint URI as
+	// defined by RFC 9278.
+	jwkThumbprintURIPrefix = "urn:ietf:params:oauth:jwk-thumbprint:sha-256:"
+)
+
+// ErrNoKeyIdentity is used when a certificate does not identify a libtrust
+// key.
+var ErrNoKeyIdentity = errors.New("certificate does not identify a libtrust key")
+
+// jwkThumbprintMembers are the members of each key type hashed by a JWK
+// thumbprint.
+var jwkThumbprintMembers = map[string][]string{
+	"EC":  {"crv", "kty", "x", "y"},
+	"RSA": {"e", "kty", "n"},
+}
+
+// JWKThumbprint returns the base64url encoded SHA-256 JWK thumbprint of the
+// key, as defined by RFC 7638.
+func JWKThumbprint(key PublicKey) (string, error) {
+	jwkBytes, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	var jwk map[string]interface{}
+	if err := json.Unmarshal(jwkBytes, &jwk); err != nil {
+		return "", err
+	}
+
+	members, ok := jwkThumbprintMembers[key.KeyType()]
+	if !ok {
+		return "", fmt.Errorf("unsupported key type %s", key.KeyType())
+	}
+	required := make(map[string]interface{}, len(members))
+	for _, member := range members {
+		required[member] = jwk[member]
+	}
+	// Map keys are marshalled in lexicographic order without whitespace,
+	// as required for thumbprints.
+	canonical, err := json.Marshal(required)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(canonical)
+	return joseBase64UrlEncode(hash[:]), nil
+}
+
+// KeyIDURI returns the URI identifying the key in certificates generated
+// by libtrust.
+func KeyIDURI(key PublicKey) *url.URL {
+	return &url.URL{Scheme: "libtrust", Opaque: "key:" + key.KeyID()}
+}
+
+// keyIdentityURIs returns the URI subject alternative names identifying the
+// key: its key ID and its JWK thumbprint.
+func keyIdentityURIs(key PublicKey) ([]*url.URL, error) {
+	thumbprint, err := JWKThumbprint(key)
+	if err != nil {
+		return nil, err
+	}
+	thumbprintURI, err := url.Parse(jwkThumbprintURIPrefix + thumbprint)
+	if err != nil {
+		return nil, err
+	}
+	return []*url.URL{KeyIDURI(key), thumbprintURI}, nil
+}
+
+// CertificateIdentity returns the libtrust key of a certificate after
+// checking that the identities claimed by the certificate match its public
+// key. The key ID and JWK thumbprint subject alternative names, and a
+// subject key identifier in key ID form, are checked. Certificates without
+// these are accepted if their common name is the key ID.
+func CertificateIdentity(cert *x509.Certificate) (PublicKey, error) {
+	key, err := FromCryptoPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := false
+	for _, uri := range cert.URIs {
+		s := uri.String()
+		switch {
+		case strings.HasPrefix(s, KeyIDURIPrefix):
+			if keyID := strings.TrimPrefix(s, KeyIDURIPrefix); keyID != key.KeyID() {
+				return nil, fmt.Errorf("certificate claims key %s but has key %s", keyID, key.KeyID())
+			}
+			claimed = true
+		case strings.HasPrefix(s, jwkThumbprintURIPrefix):
+			thumbprint, err := JWKThumbprint(key)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimPrefix(s, jwkThumbprintURIPrefix) != thumbprint {
+				return nil, errors.New("certificate JWK thumbprint does not match its key")
+			}
+			claimed = true
+		}
+	}
+	if claimed {
+		hash := keyIDHash(key)
+		if len(cert.SubjectKeyId) == len(hash) && !bytes.Equal(cert.SubjectKeyId, hash) {
+			return nil, errors.New("certificate subject key identifier does not match its key")
+		}
+	} else if cert.Subject.CommonName != key.KeyID() {
+		return nil, ErrNoKeyIdentity
+	}
+
+	return key, nil
+}
+
+// PeerIdentity returns the libtrust key of the peer of a TLS connection,
+// checked with CertificateIdentity.
+func PeerIdentity(state *tls.ConnectionState) (PublicKey, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("peer did not present a certificate")
+	}
+	return CertificateIdentity(state.PeerCertificates[0])
+}
@@ -0,0 +1,100 @@
+package libtrust
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/docker/libtrust/testutil"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	// Example key and thumbprint from RFC 7638, section 3.1.
+	jwk := []byte(`{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256"}`)
+	key, err := UnmarshalPublicKeyJWK(jwk)
+	if err != nil {
+		t.Fatalf("Error unmarshalling key: %s", err)
+	}
+	thumbprint, err := JWKThumbprint(key)
+	if err != nil {
+		t.Fatalf("Error computing thumbprint: %s", err)
+	}
+	if expected := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; thumbprint != expected {
+		t.Fatalf("Unexpected thumbprint %s, expected %s", thumbprint, expected)
+	}
+}
+
+func TestCertificateIdentity(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	cert, err := GenerateSelfSignedClientCertWithOptions(key, &CertificateOptions{Subject: pkix.Name{CommonName: "client"}})
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err)
+	}
+	if len(cert.URIs) != 2 || cert.URIs[0].String() != KeyIDURIPrefix+key.KeyID() {
+		t.Fatalf("Unexpected URIs %v", cert.URIs)
+	}
+	if keyIDEncode(cert.SubjectKeyId) != key.KeyID() {
+		t.Fatalf("Subject key identifier does not encode key ID")
+	}
+	identity, err := CertificateIdentity(cert)
+	if err != nil {
+		t.Fatalf("Error getting certificate identity: %s", err)
+	}
+	if identity.KeyID() != key.KeyID() {
+		t.Fatalf("Unexpected identity %s", identity.KeyID())
+	}
+	identity, err = PeerIdentity(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})
+	if err != nil || identity.KeyID() != key.KeyID() {
+		t.Fatalf("Unexpected peer identity: %v", err)
+	}
+	if _, err := PeerIdentity(&tls.ConnectionState{}); err == nil {
+		t.Fatalf("Expected error for peer without certificate")
+	}
+
+	// A certificate claiming another key is rejected.
+	otherURIs, err := keyIdentityURIs(otherKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Error generating identity URIs: %s", err)
+	}
+	for _, uri := range otherURIs {
+		forged, err := generateCert(key.PublicKey(), key, &certTemplateInfo{key: key.PublicKey(), options: &CertificateOptions{URIs: []*url.URL{uri}}}, &certTemplateInfo{key: key.PublicKey()})
+		if err != nil {
+			t.Fatalf("Error generating certificate: %s", err)
+		}
+		forged.URIs = forged.URIs[2:]
+		if _, err := CertificateIdentity(forged); err == nil {
+			t.Fatalf("Expected error for certificate claiming %s", uri)
+		}
+	}
+
+	// Certificates identified by their common name only are accepted.
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating CA: %s", err)
+	}
+	if _, err := CertificateIdentity(ca); err != ErrNoKeyIdentity {
+		t.Fatalf("Expected no key identity error, got %v", err)
+	}
+	legacy, err := testutil.GenerateTrustCert(key.CryptoPublicKey(), caKey.CryptoPrivateKey(), ca)
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err)
+	}
+	legacy.Subject.CommonName = key.KeyID()
+	if _, err := CertificateIdentity(legacy); err != nil {
+		t.Fatalf("Error getting legacy certificate identity: %s", err)
+	}
+}
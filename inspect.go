@@ -0,0 +1,368 @@
+package libtrust
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jwkMembers are the members of a JWK which are not extended fields.
+var jwkMembers = map[string]bool{
+	"kty": true, "kid": true, "crv": true, "x": true, "y": true, "n": true, "e": true,
+}
+
+// KeyDescription is a printable description of a public key. It renders as
+// text with String and as JSON with encoding/json.
+type KeyDescription struct {
+	// Type is the key type, "EC" or "RSA".
+	Type string `json:"type"`
+	// Size is the size of the key in bits.
+	Size int `json:"size"`
+	// Curve is the name of the curve of an EC key.
+	Curve string `json:"curve,omitempty"`
+	// KeyID is the libtrust key ID.
+	KeyID string `json:"keyID"`
+	// Thumbprint is the RFC 7638 JWK thumbprint.
+	Thumbprint string `json:"thumbprint"`
+	// Extended are the extended fields of the key.
+	Extended map[string]interface{} `json:"extended,omitempty"`
+}
+
+// DescribeKey returns a description of a public key.
+func DescribeKey(key PublicKey) (*KeyDescription, error) {
+	d := &KeyDescription{
+		Type:  key.KeyType(),
+		KeyID: key.KeyID(),
+	}
+	switch cryptoKey := key.CryptoPublicKey().(type) {
+	case *ecdsa.PublicKey:
+		d.Size = cryptoKey.Params().BitSize
+		d.Curve = cryptoKey.Params().Name
+	case *rsa.PublicKey:
+		d.Size = cryptoKey.N.BitLen()
+	}
+
+	thumbprint, err := JWKThumbprint(key)
+	if err != nil {
+		return nil, err
+	}
+	d.Thumbprint = thumbprint
+
+	jwkBytes, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	var jwk map[string]interface{}
+	if err := json.Unmarshal(jwkBytes, &jwk); err != nil {
+		return nil, err
+	}
+	for name, value := range jwk {
+		if jwkMembers[name] {
+			continue
+		}
+		if d.Extended == nil {
+			d.Extended = make(map[string]interface{})
+		}
+		d.Extended[name] = value
+	}
+
+	return d, nil
+}
+
+// String renders the description as text.
+func (d *KeyDescription) String() string {
+	var buf bytes.Buffer
+	d.writeText(&buf, "")
+	return buf.String()
+}
+
+func (d *KeyDescription) writeText(buf *bytes.Buffer, indent string) {
+	writeTextField(buf, indent, "Key ID", d.KeyID)
+	writeTextField(buf, indent, "Type", d.Type)
+	writeTextField(buf, indent, "Size", d.Size)
+	if d.Curve != "" {
+		writeTextField(buf, indent, "Curve", d.Curve)
+	}
+	writeTextField(buf, indent, "Thumbprint", d.Thumbprint)
+	if len(d.Extended) > 0 {
+		writeTextField(buf, indent, "Extended fields", "")
+		names := make([]string, 0, len(d.Extended))
+		for name := range d.Extended {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, err := json.Marshal(d.Extended[name])
+			if err != nil {
+				value = []byte(fmt.Sprint(d.Extended[name]))
+			}
+			writeTextField(buf, indent+"  ", name, string(value))
+		}
+	}
+}
+
+// CertificateDescription is a printable description of an x509
+// certificate. It renders as text with String and as JSON with
+// encoding/json.
+type CertificateDescription struct {
+	// Subject and Issuer are the distinguished names of the certificate.
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer"`
+	// SerialNumber is the serial number in decimal.
+	SerialNumber string `json:"serialNumber"`
+	// KeyID is the libtrust key ID of the certified key, empty if its
+	// type is not supported.
+	KeyID string `json:"keyID,omitempty"`
+	// IssuerKeyID is the libtrust key ID of the issuer, empty if the
+	// certificate does not identify it.
+	IssuerKeyID string `json:"issuerKeyID,omitempty"`
+	// DNSNames, IPAddresses, URIs and EmailAddresses are the subject
+	// alternative names.
+	DNSNames       []string `json:"dnsNames,omitempty"`
+	IPAddresses    []string `json:"ipAddresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+	// NotBefore and NotAfter bound the validity period.
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	// IsCA is whether the certificate may sign certificates.
+	IsCA bool `json:"isCA"`
+	// Fingerprint is the CertificateFingerprint of the certificate.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// DescribeCertificate returns a description of a certificate. The issuer
+// key ID is known for self-signed certificates and for certificates whose
+// authority key identifier is in the form used by libtrust.
+func DescribeCertificate(cert *x509.Certificate) *CertificateDescription {
+	d := &CertificateDescription{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		SerialNumber:   cert.SerialNumber.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		IsCA:           cert.IsCA,
+		Fingerprint:    CertificateFingerprint(cert),
+	}
+	for _, ip := range cert.IPAddresses {
+		d.IPAddresses = append(d.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		d.URIs = append(d.URIs, uri.String())
+	}
+
+	if key, err := FromCryptoPublicKey(cert.PublicKey); err == nil {
+		d.KeyID = key.KeyID()
+	}
+	switch {
+	case len(cert.AuthorityKeyId) == keyIDHashLength:
+		d.IssuerKeyID = keyIDEncode(cert.AuthorityKeyId)
+	case d.KeyID != "" && bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil:
+		d.IssuerKeyID = d.KeyID
+	}
+
+	return d
+}
+
+// DescribeCertificateChain returns a description of each certificate of a
+// chain, in chain order.
+func DescribeCertificateChain(chain []*x509.Certificate) []*CertificateDescription {
+	descriptions := make([]*CertificateDescription, len(chain))
+	for i, cert := range chain {
+		descriptions[i] = DescribeCertificate(cert)
+	}
+	return descriptions
+}
+
+// String renders the description as text.
+func (d *CertificateDescription) String() string {
+	var buf bytes.Buffer
+	d.writeText(&buf, "")
+	return buf.String()
+}
+
+func (d *CertificateDescription) writeText(buf *bytes.Buffer, indent string) {
+	writeTextField(buf, indent, "Subject", d.Subject)
+	writeTextField(buf, indent, "Issuer", d.Issuer)
+	writeTextField(buf, indent, "Serial number", d.SerialNumber)
+	if d.KeyID != "" {
+		writeTextField(buf, indent, "Key ID", d.KeyID)
+	}
+	if d.IssuerKeyID != "" {
+		writeTextField(buf, indent, "Issuer key ID", d.IssuerKeyID)
+	}
+	for _, names := range []struct {
+		label  string
+		values []string
+	}{
+		{"DNS names", d.DNSNames},
+		{"IP addresses", d.IPAddresses},
+		{"URIs", d.URIs},
+		{"Email addresses", d.EmailAddresses},
+	} {
+		if len(names.values) > 0 {
+			writeTextField(buf, indent, names.label, strings.Join(names.values, ", "))
+		}
+	}
+	writeTextField(buf, indent, "Not before", d.NotBefore.UTC().Format(time.RFC3339))
+	writeTextField(buf, indent, "Not after", d.NotAfter.UTC().Format(time.RFC3339))
+	writeTextField(buf, indent, "CA", d.IsCA)
+	writeTextField(buf, indent, "Fingerprint", d.Fingerprint)
+}
+
+// SignatureDescription is a printable description of a signature of a
+// JSONSignature.
+type SignatureDescription struct {
+	// Index is the position of the signature in the JSONSignature.
+	Index int `json:"index"`
+	// Algorithm is the signature algorithm from the signature header.
+	Algorithm string `json:"algorithm"`
+	// Signer describes the key which produced the signature, nil if it
+	// could not be determined.
+	Signer *KeyDescription `json:"signer,omitempty"`
+	// Time is the signing time claimed by the protected header, zero if
+	// it has none.
+	Time time.Time `json:"time"`
+	// Countersigns is the ID of the key which made the signature covered
+	// by this countersignature, empty if this is not a countersignature.
+	Countersigns string `json:"countersigns,omitempty"`
+	// Chain describes the x509 chain of the signature, if any.
+	Chain []*CertificateDescription `json:"chain,omitempty"`
+	// Valid is whether the signature verifies with the signer key. Chains
+	// are not verified.
+	Valid bool `json:"valid"`
+	// Error describes why the signature is not valid or why part of the
+	// description is missing.
+	Error string `json:"error,omitempty"`
+}
+
+// JWSDescription is a printable description of the signatures of a
+// JSONSignature. It renders as text with String and as JSON with
+// encoding/json.
+type JWSDescription struct {
+	// PayloadSize is the size of the payload in bytes.
+	PayloadSize int `json:"payloadSize"`
+	// Signatures describe the signatures in signature order.
+	Signatures []*SignatureDescription `json:"signatures"`
+}
+
+// DescribeJWS returns a description of the signatures of a JSONSignature,
+// such as one parsed with ParseJWS or ParsePrettySignature. Problems with
+// individual signatures are reported in their descriptions rather than as
+// an error.
+func DescribeJWS(js *JSONSignature) *JWSDescription {
+	d := &JWSDescription{
+		Signatures: make([]*SignatureDescription, len(js.signatures)),
+	}
+	if payload, err := js.Payload(); err == nil {
+		d.PayloadSize = len(payload)
+	}
+	for i := range js.signatures {
+		d.Signatures[i] = js.describeSignature(i)
+	}
+	return d
+}
+
+func (js *JSONSignature) describeSignature(index int) *SignatureDescription {
+	s := &js.signatures[index]
+	info := js.signatureInfo(index)
+	d := &SignatureDescription{
+		Index:        index,
+		Algorithm:    info.Algorithm,
+		Countersigns: info.Countersigns,
+		Chain:        DescribeCertificateChain(info.Chain),
+	}
+	var errs []string
+	if signingTime, err := s.signingTime(); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		d.Time = signingTime
+	}
+	if key, err := s.publicKey(); err != nil {
+		errs = append(errs, err.Error())
+	} else if d.Signer, err = DescribeKey(key); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := js.verifySignature(s); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		d.Valid = true
+	}
+	d.Error = strings.Join(errs, "; ")
+	return d
+}
+
+// signingTime returns the time claimed by the protected header of the
+// signature, or the zero time if it has none.
+func (s *jsSignature) signingTime() (time.Time, error) {
+	protectedBytes, err := joseBase64UrlDecode(s.Protected)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("base64 decode error: %s", err)
+	}
+	var protected struct {
+		Time string `json:"time"`
+	}
+	if err := json.Unmarshal(protectedBytes, &protected); err != nil {
+		return time.Time{}, fmt.Errorf("error unmarshalling protected header: %s", err)
+	}
+	if protected.Time == "" {
+		return time.Time{}, nil
+	}
+	signingTime, err := time.Parse(time.RFC3339, protected.Time)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid signing time: %s", err)
+	}
+	return signingTime, nil
+}
+
+// String renders the description as text.
+func (d *JWSDescription) String() string {
+	var buf bytes.Buffer
+	writeTextField(&buf, "", "Payload size", d.PayloadSize)
+	for _, s := range d.Signatures {
+		writeTextField(&buf, "", fmt.Sprintf("Signature %d", s.Index), "")
+		s.writeText(&buf, "  ")
+	}
+	return buf.String()
+}
+
+func (d *SignatureDescription) writeText(buf *bytes.Buffer, indent string) {
+	writeTextField(buf, indent, "Algorithm", d.Algorithm)
+	if !d.Time.IsZero() {
+		writeTextField(buf, indent, "Time", d.Time.UTC().Format(time.RFC3339))
+	}
+	if d.Countersigns != "" {
+		writeTextField(buf, indent, "Countersigns", d.Countersigns)
+	}
+	writeTextField(buf, indent, "Valid", d.Valid)
+	if d.Error != "" {
+		writeTextField(buf, indent, "Error", d.Error)
+	}
+	if d.Signer != nil {
+		writeTextField(buf, indent, "Signer", "")
+		d.Signer.writeText(buf, indent+"  ")
+	}
+	for i, cert := range d.Chain {
+		writeTextField(buf, indent, fmt.Sprintf("Certificate %d", i), "")
+		cert.writeText(buf, indent+"  ")
+	}
+}
+
+// writeTextField writes a line of a text description. A field with an
+// empty value introduces the indented fields which follow.
+func writeTextField(buf *bytes.Buffer, indent, name string, value interface{}) {
+	if s, ok := value.(string); ok && s == "" {
+		fmt.Fprintf(buf, "%s%s:\n", indent, name)
+		return
+	}
+	fmt.Fprintf(buf, "%s%s: %v\n", indent, name, value)
+}
@@ -0,0 +1,148 @@
+package libtrust
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeKey(t *testing.T) {
+	ecKey := generateECTestKeys(t)[1]
+	ecKey.PublicKey().AddExtendedField("hosts", []string{"example.com"})
+	for _, key := range []PrivateKey{ecKey, rsaKeys[0]} {
+		d, err := DescribeKey(key.PublicKey())
+		if err != nil {
+			t.Fatalf("Error describing key: %s", err)
+		}
+		thumbprint, err := JWKThumbprint(key.PublicKey())
+		if err != nil {
+			t.Fatalf("Error computing thumbprint: %s", err)
+		}
+		if d.KeyID != key.KeyID() || d.Type != key.KeyType() || d.Thumbprint != thumbprint {
+			t.Fatalf("Unexpected key description: %+v", d)
+		}
+		if !strings.Contains(d.String(), "Key ID: "+key.KeyID()) {
+			t.Fatalf("Unexpected text description:\n%s", d)
+		}
+	}
+
+	d, err := DescribeKey(ecKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Error describing key: %s", err)
+	}
+	if d.Size != 384 || d.Curve != "P-384" {
+		t.Fatalf("Unexpected size %d and curve %q", d.Size, d.Curve)
+	}
+	if len(d.Extended) != 1 || !strings.Contains(d.String(), `hosts: ["example.com"]`) {
+		t.Fatalf("Unexpected extended fields: %v", d.Extended)
+	}
+	d, err = DescribeKey(rsaKeys[0].PublicKey())
+	if err != nil {
+		t.Fatalf("Error describing key: %s", err)
+	}
+	if d.Size != rsaKeys[0].CryptoPublicKey().(interface{ Size() int }).Size()*8 || d.Curve != "" {
+		t.Fatalf("Unexpected size %d and curve %q", d.Size, d.Curve)
+	}
+}
+
+func TestDescribeCertificate(t *testing.T) {
+	keys := generateECTestKeys(t)
+	chain, err := GenerateKeyHierarchyChain(keys[:2], nil, keys[2].PublicKey(), &CertificateOptions{EmailAddresses: []string{"signer@example.com"}})
+	if err != nil {
+		t.Fatalf("Error generating chain: %s", err)
+	}
+	descriptions := DescribeCertificateChain(chain)
+	for i, issuer := range []PrivateKey{keys[1], keys[0], keys[0]} {
+		if descriptions[i].IssuerKeyID != issuer.KeyID() {
+			t.Fatalf("Unexpected issuer key ID of certificate %d: %q", i, descriptions[i].IssuerKeyID)
+		}
+	}
+	leaf := descriptions[0]
+	if leaf.KeyID != keys[2].KeyID() || leaf.IsCA || len(leaf.EmailAddresses) != 1 || len(leaf.URIs) != 2 {
+		t.Fatalf("Unexpected leaf description: %+v", leaf)
+	}
+	if !strings.Contains(leaf.String(), "Email addresses: signer@example.com") {
+		t.Fatalf("Unexpected text description:\n%s", leaf)
+	}
+
+	cert, err := GenerateSelfSignedClientCert(keys[0])
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err)
+	}
+	if d := DescribeCertificate(cert); d.IssuerKeyID != keys[0].KeyID() {
+		t.Fatalf("Unexpected issuer key ID of self-signed certificate: %q", d.IssuerKeyID)
+	}
+}
+
+func TestDescribeJWS(t *testing.T) {
+	keys := generateECTestKeys(t)
+	chain, err := GenerateKeyHierarchyChain(keys[:1], nil, keys[1].PublicKey(), nil)
+	if err != nil {
+		t.Fatalf("Error generating chain: %s", err)
+	}
+	js, err := NewJSONSignature([]byte(`{"name": "inspected"}`))
+	if err != nil {
+		t.Fatalf("Error creating signature: %s", err)
+	}
+	if err := js.SignWithChain(keys[1], chain); err != nil {
+		t.Fatalf("Error signing with chain: %s", err)
+	}
+	if err := js.Countersign(keys[2], keys[1].KeyID()); err != nil {
+		t.Fatalf("Error countersigning: %s", err)
+	}
+	pretty, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	parsed, err := ParsePrettySignature(pretty, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing signature: %s", err)
+	}
+
+	d := DescribeJWS(parsed)
+	if len(d.Signatures) != 2 {
+		t.Fatalf("Expected 2 signatures, got %d", len(d.Signatures))
+	}
+	signed, countersigned := d.Signatures[0], d.Signatures[1]
+	if signed.Signer == nil || signed.Signer.KeyID != keys[1].KeyID() {
+		signed, countersigned = countersigned, signed
+	}
+	if signed.Signer.KeyID != keys[1].KeyID() || len(signed.Chain) != 2 || !signed.Valid || signed.Error != "" {
+		t.Fatalf("Unexpected signature description: %+v", signed)
+	}
+	if signed.Chain[1].KeyID != keys[0].KeyID() {
+		t.Fatalf("Unexpected chain description: %+v", signed.Chain[1])
+	}
+	if time.Since(signed.Time) > time.Minute {
+		t.Fatalf("Unexpected signing time: %s", signed.Time)
+	}
+	if countersigned.Countersigns != keys[1].KeyID() || len(countersigned.Chain) != 0 || !countersigned.Valid {
+		t.Fatalf("Unexpected countersignature description: %+v", countersigned)
+	}
+	text := d.String()
+	for _, expected := range []string{"Signature 1:", "Countersigns: " + keys[1].KeyID(), "    Key ID: " + keys[0].KeyID()} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("Expected %q in text description:\n%s", expected, text)
+		}
+	}
+
+	jsonBytes, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Error marshalling description: %s", err)
+	}
+	var decoded JWSDescription
+	if err := json.Unmarshal(jsonBytes, &decoded); err != nil {
+		t.Fatalf("Error unmarshalling description: %s", err)
+	}
+	if decoded.PayloadSize != d.PayloadSize || len(decoded.Signatures) != 2 || decoded.Signatures[0].Algorithm != d.Signatures[0].Algorithm {
+		t.Fatalf("Unexpected decoded description: %s", jsonBytes)
+	}
+
+	// Invalid signatures are described rather than rejected.
+	parsed.signatures[0].Signature = parsed.signatures[1].Signature
+	d = DescribeJWS(parsed)
+	if d.Signatures[0].Valid || d.Signatures[0].Error == "" {
+		t.Fatalf("Expected invalid signature description: %+v", d.Signatures[0])
+	}
+}
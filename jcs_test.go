@@ -0,0 +1,82 @@
+package libtrust
+
+import (
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	// Example from section 3.2.2 of RFC 8785.
+	input := `{
+  "numbers": [333333333.33333329, 1E30, 4.50,
+              2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`
+	expected := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+
+	canonical, err := CanonicalJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("Error canonicalizing: %s", err)
+	}
+	if string(canonical) != expected {
+		t.Fatalf("Unexpected canonical form\nExpected:\n%s\nActual:\n%s", expected, canonical)
+	}
+}
+
+func TestCanonicalJSONSorting(t *testing.T) {
+	// Example from section 3.2.3 of RFC 8785, members are sorted by UTF-16
+	// code units rather than by UTF-8 bytes.
+	input := `{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`
+	expected := "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"
+
+	canonical, err := CanonicalJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("Error canonicalizing: %s", err)
+	}
+	if string(canonical) != expected {
+		t.Fatalf("Unexpected canonical form\nExpected:\n%s\nActual:\n%s", expected, canonical)
+	}
+}
+
+func TestCanonicalNumbers(t *testing.T) {
+	for _, c := range []struct {
+		input    float64
+		expected string
+	}{
+		{0, "0"},
+		{-0.0, "0"},
+		{1, "1"},
+		{-1.5, "-1.5"},
+		{1e20, "100000000000000000000"},
+		{1e21, "1e+21"},
+		{123e18, "123000000000000000000"},
+		{0.000001, "0.000001"},
+		{1e-7, "1e-7"},
+		{1.25e-7, "1.25e-7"},
+		{9007199254740991, "9007199254740991"},
+		{5e-324, "5e-324"},
+		{1.7976931348623157e308, "1.7976931348623157e+308"},
+	} {
+		s, err := canonicalNumber(c.input)
+		if err != nil {
+			t.Fatalf("Error formatting %v: %s", c.input, err)
+		}
+		if s != c.expected {
+			t.Fatalf("Unexpected format of %v: %s, expected %s", c.input, s, c.expected)
+		}
+	}
+}
+
+func TestCanonicalJSONInvalid(t *testing.T) {
+	for _, input := range []string{
+		`{"a": 1, "a": 2}`,
+		`{"a": {"b": 1, "b": 1}}`,
+		`{"a": 1} {}`,
+		`{"a": 1e400}`,
+		`{"a": `,
+	} {
+		if _, err := CanonicalJSON([]byte(input)); err == nil {
+			t.Fatalf("Expected error canonicalizing %s", input)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package libtrust
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidJSONPointer is used when a signature location is not a valid
+// non-empty JSON pointer as defined by RFC 6901.
+var ErrInvalidJSONPointer = errors.New("invalid json pointer")
+
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parseJSONPointer splits a JSON pointer into its unescaped reference
+// tokens. The empty pointer, which refers to the whole document, cannot
+// name a member and is rejected.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, ErrInvalidJSONPointer
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, ErrInvalidJSONPointer
+			}
+		}
+		tokens[i] = jsonPointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// jsonPointerIndex parses a reference token into an array index.
+func jsonPointerIndex(token string) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+// seekJSONPointer advances the decoder to the value referenced by the
+// given tokens, so that the next token read is the start of that value.
+func seekJSONPointer(dec *json.Decoder, tokens []string) error {
+	for _, token := range tokens {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'):
+			found := false
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				if key == token {
+					found = true
+					break
+				}
+				var skipped json.RawMessage
+				if err := dec.Decode(&skipped); err != nil {
+					return err
+				}
+			}
+			if !found {
+				return fmt.Errorf("member %q not found", token)
+			}
+		case json.Delim('['):
+			index, err := jsonPointerIndex(token)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < index; i++ {
+				if !dec.More() {
+					break
+				}
+				var skipped json.RawMessage
+				if err := dec.Decode(&skipped); err != nil {
+					return err
+				}
+			}
+			if !dec.More() {
+				return fmt.Errorf("array index %d out of range", index)
+			}
+		default:
+			return fmt.Errorf("cannot resolve %q within a scalar value", token)
+		}
+	}
+	return nil
+}
+
+// lookupJSONPointer returns the raw value referenced by the given tokens.
+func lookupJSONPointer(content []byte, tokens []string) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	if err := seekJSONPointer(dec, tokens); err != nil {
+		return nil, err
+	}
+	var value json.RawMessage
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// objectInsertionIndex returns the index in content at which members can
+// be added to the object referenced by the given tokens: after its last
+// member and before any whitespace preceding its closing brace.
+func objectInsertionIndex(content []byte, tokens []string) (int, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	if err := seekJSONPointer(dec, tokens); err != nil {
+		return 0, err
+	}
+	t, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	if t != json.Delim('{') {
+		return 0, errors.New("signature location is not within an object")
+	}
+	for dec.More() {
+		if _, err := dec.Token(); err != nil {
+			return 0, err
+		}
+		var skipped json.RawMessage
+		if err := dec.Decode(&skipped); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return 0, err
+	}
+	closeIndex := int(dec.InputOffset()) - 1
+	return bytes.LastIndexFunc(content[:closeIndex], notSpace) + 1, nil
+}
+
+// canonicalParent returns the decoded object referenced by the given
+// tokens within a value returned by decodeCanonical.
+func canonicalParent(value interface{}, tokens []string) (map[string]interface{}, error) {
+	for _, token := range tokens {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			member, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			value = member
+		case []interface{}:
+			index, err := jsonPointerIndex(token)
+			if err != nil {
+				return nil, err
+			}
+			if index >= len(v) {
+				return nil, fmt.Errorf("array index %d out of range", index)
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("cannot resolve %q within a scalar value", token)
+		}
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("signature location is not within an object")
+	}
+	return object, nil
+}
@@ -0,0 +1,56 @@
+package libtrust
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONPointer(t *testing.T) {
+	valid := map[string][]string{
+		"/signatures":          {"signatures"},
+		"/metadata/signatures": {"metadata", "signatures"},
+		"/a~1b/c~0d":           {"a/b", "c~d"},
+		"/0/":                  {"0", ""},
+	}
+	for pointer, expected := range valid {
+		tokens, err := parseJSONPointer(pointer)
+		if err != nil {
+			t.Fatalf("Error parsing %q: %s", pointer, err)
+		}
+		if !reflect.DeepEqual(tokens, expected) {
+			t.Fatalf("Unexpected tokens for %q: %q", pointer, tokens)
+		}
+	}
+
+	for _, pointer := range []string{"", "signatures", "/a~2", "/a~"} {
+		if _, err := parseJSONPointer(pointer); err != ErrInvalidJSONPointer {
+			t.Fatalf("Expected invalid pointer error for %q, got %v", pointer, err)
+		}
+	}
+}
+
+func TestObjectInsertionIndex(t *testing.T) {
+	content := []byte(`[{"a": 1}, {"b": {"c": [], "d": {}}  }]`)
+	for _, test := range []struct {
+		tokens   []string
+		expected string
+	}{
+		{[]string{"0"}, `[{"a": 1`},
+		{[]string{"1"}, `[{"a": 1}, {"b": {"c": [], "d": {}}`},
+		{[]string{"1", "b", "d"}, `[{"a": 1}, {"b": {"c": [], "d": {`},
+	} {
+		index, err := objectInsertionIndex(content, test.tokens)
+		if err != nil {
+			t.Fatalf("Error finding insertion index for %q: %s", test.tokens, err)
+		}
+		if string(content[:index]) != test.expected {
+			t.Fatalf("Unexpected insertion index for %q: %s", test.tokens, content[:index])
+		}
+	}
+
+	for _, tokens := range [][]string{nil, {"2"}, {"1", "b", "c"}, {"1", "e"}, {"0", "a", "x"}} {
+		if _, err := objectInsertionIndex(content, tokens); err == nil {
+			t.Fatalf("Expected error finding insertion index for %q", tokens)
+		}
+	}
+}
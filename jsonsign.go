@@ -3,12 +3,17 @@ package libtrust
 import (
 	"bytes"
 	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"runtime"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
@@ -25,10 +30,16 @@ var (
 	ErrMissingSignatureKey = errors.New("missing signature key")
 )
 
+// canonicalJCS is the value of the "canonical" protected header member of
+// signatures over the RFC 8785 canonical form of the payload.
+const canonicalJCS = "jcs"
+
 type jsHeader struct {
-	JWK       PublicKey `json:"jwk,omitempty"`
-	Algorithm string    `json:"alg"`
-	Chain     []string  `json:"x5c,omitempty"`
+	JWK       PublicKey       `json:"jwk,omitempty"`
+	Algorithm string          `json:"alg"`
+	Chain     []string        `json:"x5c,omitempty"`
+	Timestamp json.RawMessage `json:"timestamp,omitempty"`
+	OCSP      []string        `json:"ocsp,omitempty"`
 }
 
 type jsSignature struct {
@@ -37,13 +48,102 @@ type jsSignature struct {
 	Protected string   `json:"protected,omitempty"`
 }
 
+// publicKey returns the public key which produced the signature, taken from
+// the leaf of the x509 chain if present or the embedded JWK otherwise.
+func (s *jsSignature) publicKey() (PublicKey, error) {
+	if len(s.Header.Chain) > 0 {
+		certBytes, err := base64.StdEncoding.DecodeString(s.Header.Chain[0])
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(certBytes)
+		if err != nil {
+			return nil, err
+		}
+		return FromCryptoPublicKey(cert.PublicKey)
+	} else if s.Header.JWK != nil {
+		return s.Header.JWK, nil
+	}
+	return nil, errors.New("missing public key")
+}
+
+// keyID returns the ID of the key which produced the signature or an empty
+// string if it cannot be determined.
+func (s *jsSignature) keyID() string {
+	publicKey, err := s.publicKey()
+	if err != nil {
+		return ""
+	}
+	return publicKey.KeyID()
+}
+
+// chain returns the parsed x509 chain of the signature.
+func (s *jsSignature) chain() ([]*x509.Certificate, error) {
+	chain := make([]*x509.Certificate, len(s.Header.Chain))
+	for i, encoded := range s.Header.Chain {
+		certBytes, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		chain[i], err = x509.ParseCertificate(certBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+// digest identifies the signature within the protected header of a
+// countersignature.
+func (s *jsSignature) digest() string {
+	sum := sha256.Sum256([]byte(s.Protected + "." + s.Signature))
+	return joseBase64UrlEncode(sum[:])
+}
+
+// countersignSuffix returns the data appended to the signing input of a
+// countersignature covering this signature.
+func (s *jsSignature) countersignSuffix() string {
+	return "." + s.Protected + "." + s.Signature
+}
+
+// countersigns returns the digest of the signature covered by this
+// signature, or an empty string if it is not a countersignature.
+func (s *jsSignature) countersigns() (string, error) {
+	protectedBytes, err := joseBase64UrlDecode(s.Protected)
+	if err != nil {
+		return "", fmt.Errorf("base64 decode error: %s", err)
+	}
+	var protected struct {
+		Countersigns string `json:"countersigns"`
+	}
+	if err := json.Unmarshal(protectedBytes, &protected); err != nil {
+		return "", fmt.Errorf("error unmarshalling protected header: %s", err)
+	}
+	return protected.Countersigns, nil
+}
+
+// countersignedSignature returns the signature among signatures which is
+// covered by the given signature, or nil if it is not a countersignature.
+func countersignedSignature(signatures []jsSignature, signature *jsSignature) (*jsSignature, error) {
+	digest, err := signature.countersigns()
+	if err != nil || digest == "" {
+		return nil, err
+	}
+	for i := range signatures {
+		if signatures[i].digest() == digest {
+			return &signatures[i], nil
+		}
+	}
+	return nil, errors.New("countersigned signature not found")
+}
+
 type jsSignaturesSorted []jsSignature
 
 func (jsbkid jsSignaturesSorted) Swap(i, j int) { jsbkid[i], jsbkid[j] = jsbkid[j], jsbkid[i] }
 func (jsbkid jsSignaturesSorted) Len() int      { return len(jsbkid) }
 
 func (jsbkid jsSignaturesSorted) Less(i, j int) bool {
-	ki, kj := jsbkid[i].Header.JWK.KeyID(), jsbkid[j].Header.JWK.KeyID()
+	ki, kj := jsbkid[i].keyID(), jsbkid[j].keyID()
 	si, sj := jsbkid[i].Signature, jsbkid[j].Signature
 
 	if ki == kj {
@@ -65,8 +165,13 @@ type JSONSignature struct {
 	indent       string
 	formatLength int
 	formatTail   []byte
+	canonical    bool
 }
 
+// noSignatureLocation is the format length of a formatted array payload
+// whose signature location has not been set.
+const noSignatureLocation = -1
+
 func newJSONSignature() *JSONSignature {
 	return &JSONSignature{
 		signatures: make([]jsSignature, 0, 1),
@@ -79,11 +184,21 @@ func (js *JSONSignature) Payload() ([]byte, error) {
 	return joseBase64UrlDecode(js.payload)
 }
 
-func (js *JSONSignature) protectedHeader() (string, error) {
+func (js *JSONSignature) protectedHeader(countersigned *jsSignature) (string, error) {
+	if js.formatLength == noSignatureLocation {
+		return "", errors.New("signature location must be set before signing an array")
+	}
 	protected := map[string]interface{}{
-		"formatLength": js.formatLength,
-		"formatTail":   joseBase64UrlEncode(js.formatTail),
-		"time":         time.Now().UTC().Format(time.RFC3339),
+		"time": time.Now().UTC().Format(time.RFC3339),
+	}
+	if js.canonical {
+		protected["canonical"] = canonicalJCS
+	} else {
+		protected["formatLength"] = js.formatLength
+		protected["formatTail"] = joseBase64UrlEncode(js.formatTail)
+	}
+	if countersigned != nil {
+		protected["countersigns"] = countersigned.digest()
 	}
 	protectedBytes, err := json.Marshal(protected)
 	if err != nil {
@@ -93,72 +208,89 @@ func (js *JSONSignature) protectedHeader() (string, error) {
 	return joseBase64UrlEncode(protectedBytes), nil
 }
 
-func (js *JSONSignature) signBytes(protectedHeader string) ([]byte, error) {
-	buf := make([]byte, len(js.payload)+len(protectedHeader)+1)
-	copy(buf, protectedHeader)
-	buf[len(protectedHeader)] = '.'
-	copy(buf[len(protectedHeader)+1:], js.payload)
-	return buf, nil
+// signingInput returns a reader over the JWS signing input for the given
+// protected header. The payload is read in place rather than copied into a
+// new buffer for every signature. The signing input of a countersignature
+// is followed by the protected header and value of the signature it covers.
+func (js *JSONSignature) signingInput(protectedHeader string, countersigned *jsSignature) io.Reader {
+	input := io.MultiReader(
+		strings.NewReader(protectedHeader),
+		strings.NewReader("."),
+		strings.NewReader(js.payload),
+	)
+	if countersigned == nil {
+		return input
+	}
+	return io.MultiReader(input, strings.NewReader(countersigned.countersignSuffix()))
 }
 
 // Sign adds a signature using the given private key.
 func (js *JSONSignature) Sign(key PrivateKey) error {
-	protected, err := js.protectedHeader()
-	if err != nil {
-		return err
-	}
-	signBytes, err := js.signBytes(protected)
-	if err != nil {
-		return err
-	}
-	sigBytes, algorithm, err := key.Sign(bytes.NewReader(signBytes), crypto.SHA256)
-	if err != nil {
-		return err
-	}
-
-	js.signatures = append(js.signatures, jsSignature{
-		Header: jsHeader{
-			JWK:       key.PublicKey(),
-			Algorithm: algorithm,
-		},
-		Signature: joseBase64UrlEncode(sigBytes),
-		Protected: protected,
-	})
-
-	return nil
+	return js.sign(key, jsHeader{JWK: key.PublicKey()}, nil)
 }
 
 // SignWithChain adds a signature using the given private key
 // and setting the x509 chain. The public key of the first element
 // in the chain must be the public key corresponding with the sign key.
+// A *ChainError is returned if the leaf certificate does not match the
+// key or allow digital signatures, if each certificate is not issued by
+// the next one, or if any of them is not currently valid.
 func (js *JSONSignature) SignWithChain(key PrivateKey, chain []*x509.Certificate) error {
-	// Ensure key.Chain[0] is public key for key
-	//key.Chain.PublicKey
-	//key.PublicKey().CryptoPublicKey()
+	if err := checkSigningChain(key, chain, time.Now()); err != nil {
+		return err
+	}
+	return js.sign(key, chainHeader(chain), nil)
+}
 
-	// Verify chain
-	protected, err := js.protectedHeader()
+// Countersign adds a signature using the given private key which covers
+// the signature made by the key with the given ID in addition to the
+// payload, attesting that the signer saw that signature.
+func (js *JSONSignature) Countersign(key PrivateKey, keyID string) error {
+	countersigned, err := js.signatureByKeyID(keyID)
 	if err != nil {
 		return err
 	}
-	signBytes, err := js.signBytes(protected)
-	if err != nil {
+	return js.sign(key, jsHeader{JWK: key.PublicKey()}, countersigned)
+}
+
+// CountersignWithChain adds a signature using the given private key and
+// setting the x509 chain, which covers the signature made by the key with
+// the given ID in addition to the payload. The chain is checked as by
+// SignWithChain.
+func (js *JSONSignature) CountersignWithChain(key PrivateKey, chain []*x509.Certificate, keyID string) error {
+	if err := checkSigningChain(key, chain, time.Now()); err != nil {
 		return err
 	}
-	sigBytes, algorithm, err := key.Sign(bytes.NewReader(signBytes), crypto.SHA256)
+	countersigned, err := js.signatureByKeyID(keyID)
 	if err != nil {
 		return err
 	}
+	return js.sign(key, chainHeader(chain), countersigned)
+}
 
+func chainHeader(chain []*x509.Certificate) jsHeader {
 	header := jsHeader{
-		Chain:     make([]string, len(chain)),
-		Algorithm: algorithm,
+		Chain: make([]string, len(chain)),
 	}
 
 	for i, cert := range chain {
 		header.Chain[i] = base64.StdEncoding.EncodeToString(cert.Raw)
 	}
 
+	return header
+}
+
+func (js *JSONSignature) sign(key PrivateKey, header jsHeader, countersigned *jsSignature) error {
+	protected, err := js.protectedHeader(countersigned)
+	if err != nil {
+		return err
+	}
+	sigBytes, algorithm, err := key.Sign(js.signingInput(protected, countersigned), crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	header.Algorithm = algorithm
+
 	js.signatures = append(js.signatures, jsSignature{
 		Header:    header,
 		Signature: joseBase64UrlEncode(sigBytes),
@@ -168,114 +300,253 @@ func (js *JSONSignature) SignWithChain(key PrivateKey, chain []*x509.Certificate
 	return nil
 }
 
+// signatureByKeyID returns the only signature made by the key with the
+// given ID.
+func (js *JSONSignature) signatureByKeyID(keyID string) (*jsSignature, error) {
+	var found *jsSignature
+	for i := range js.signatures {
+		if js.signatures[i].keyID() != keyID {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("multiple signatures by key %q", keyID)
+		}
+		found = &js.signatures[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no signature by key %q", keyID)
+	}
+	return found, nil
+}
+
 // Verify verifies all the signatures and returns the list of
-// public keys used to sign. Any x509 chains are not checked.
+// public keys used to sign. Any x509 chains are not checked. Failures are
+// returned as a *SignatureError.
 func (js *JSONSignature) Verify() ([]PublicKey, error) {
 	keys := make([]PublicKey, len(js.signatures))
-	for i, signature := range js.signatures {
-		signBytes, err := js.signBytes(signature.Protected)
+	for i := range js.signatures {
+		publicKey, err := js.verifySignature(&js.signatures[i])
 		if err != nil {
-			return nil, err
-		}
-		var publicKey PublicKey
-		if len(signature.Header.Chain) > 0 {
-			certBytes, err := base64.StdEncoding.DecodeString(signature.Header.Chain[0])
-			if err != nil {
-				return nil, err
-			}
-			cert, err := x509.ParseCertificate(certBytes)
-			if err != nil {
-				return nil, err
-			}
-			publicKey, err = FromCryptoPublicKey(cert.PublicKey)
-			if err != nil {
-				return nil, err
-			}
-		} else if signature.Header.JWK != nil {
-			publicKey = signature.Header.JWK
-		} else {
-			return nil, errors.New("missing public key")
+			return nil, js.signatureError(i, err)
 		}
+		keys[i] = publicKey
+	}
+	return keys, nil
+}
 
-		sigBytes, err := joseBase64UrlDecode(signature.Signature)
-		if err != nil {
-			return nil, err
-		}
+// verifySignature verifies a single signature and returns the public key
+// used to sign.
+func (js *JSONSignature) verifySignature(signature *jsSignature) (PublicKey, error) {
+	publicKey, err := signature.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	countersigned, err := countersignedSignature(js.signatures, signature)
+	if err != nil {
+		return nil, err
+	}
 
-		err = publicKey.Verify(bytes.NewReader(signBytes), signature.Header.Algorithm, sigBytes)
-		if err != nil {
-			return nil, err
-		}
+	sigBytes, err := joseBase64UrlDecode(signature.Signature)
+	if err != nil {
+		return nil, err
+	}
 
-		keys[i] = publicKey
+	err = publicKey.Verify(js.signingInput(signature.Protected, countersigned), signature.Header.Algorithm, sigBytes)
+	if err != nil {
+		return nil, verificationFailure(FailureInvalidSignature, err)
 	}
-	return keys, nil
+
+	return publicKey, nil
 }
 
 // VerifyChains verifies all the signatures and the chains associated
 // with each signature and returns the list of verified chains.
 // Signatures without an x509 chain are not checked.
+//
+// Failures are returned as a *SignatureError, which wraps any crypto/x509
+// chain error such as x509.UnknownAuthorityError; use errors.As to inspect
+// the cause.
 func (js *JSONSignature) VerifyChains(ca *x509.CertPool) ([][]*x509.Certificate, error) {
 	chains := make([][]*x509.Certificate, 0, len(js.signatures))
-	for _, signature := range js.signatures {
-		signBytes, err := js.signBytes(signature.Protected)
+	for i := range js.signatures {
+		verifiedChains, err := js.verifySignatureChains(&js.signatures[i], &ChainVerifyOptions{Roots: ca})
 		if err != nil {
-			return nil, err
+			return nil, js.signatureError(i, err)
 		}
-		var publicKey PublicKey
-		if len(signature.Header.Chain) > 0 {
-			certBytes, err := base64.StdEncoding.DecodeString(signature.Header.Chain[0])
-			if err != nil {
-				return nil, err
-			}
-			cert, err := x509.ParseCertificate(certBytes)
+		chains = append(chains, verifiedChains...)
+	}
+	return chains, nil
+}
+
+// verifySignatureChains verifies a single signature and its x509 chain
+// according to the given options and returns the verified chains. A
+// signature without a chain is not checked unless a chain is required.
+func (js *JSONSignature) verifySignatureChains(signature *jsSignature, opts *ChainVerifyOptions) ([][]*x509.Certificate, error) {
+	if len(signature.Header.Chain) == 0 {
+		if opts.RequireChain {
+			return nil, verificationFailure(FailureMissingChain, ErrMissingChain)
+		}
+		return nil, nil
+	}
+
+	certBytes, err := base64.StdEncoding.DecodeString(signature.Header.Chain[0])
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := FromCryptoPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	intermediates := x509.NewCertPool()
+	if len(signature.Header.Chain) > 1 {
+		intermediateChain := signature.Header.Chain[1:]
+		for i := range intermediateChain {
+			certBytes, err := base64.StdEncoding.DecodeString(intermediateChain[i])
 			if err != nil {
 				return nil, err
 			}
-			publicKey, err = FromCryptoPublicKey(cert.PublicKey)
+			intermediate, err := x509.ParseCertificate(certBytes)
 			if err != nil {
 				return nil, err
 			}
-			intermediates := x509.NewCertPool()
-			if len(signature.Header.Chain) > 1 {
-				intermediateChain := signature.Header.Chain[1:]
-				for i := range intermediateChain {
-					certBytes, err := base64.StdEncoding.DecodeString(intermediateChain[i])
-					if err != nil {
-						return nil, err
-					}
-					intermediate, err := x509.ParseCertificate(certBytes)
-					if err != nil {
-						return nil, err
-					}
-					intermediates.AddCert(intermediate)
-				}
-			}
+			intermediates.AddCert(intermediate)
+		}
+	}
 
-			verifyOptions := x509.VerifyOptions{
-				Intermediates: intermediates,
-				Roots:         ca,
-			}
+	verifyOptions := x509.VerifyOptions{
+		Intermediates: intermediates,
+		Roots:         opts.Roots,
+		CurrentTime:   opts.CurrentTime,
+		DNSName:       opts.DNSName,
+		KeyUsages:     opts.KeyUsages,
+	}
 
-			verifiedChains, err := cert.Verify(verifyOptions)
-			if err != nil {
-				return nil, err
-			}
-			chains = append(chains, verifiedChains...)
+	verifiedChains, err := cert.Verify(verifyOptions)
+	if err != nil {
+		return nil, chainFailure(err)
+	}
+	if err := opts.checkLeaf(cert); err != nil {
+		return nil, verificationFailure(FailureInvalidChain, err)
+	}
+	if opts.Revocation != nil {
+		if err := checkRevocation(opts.Revocation, verifiedChains, opts.CurrentTime); err != nil {
+			return nil, verificationFailure(FailureRevoked, err)
+		}
+	}
+	if len(signature.Header.OCSP) > 0 || opts.RequireOCSP {
+		now := opts.CurrentTime
+		if now.IsZero() {
+			now = time.Now()
+		}
+		if err := checkStapledOCSP(signature.Header.OCSP, verifiedChains, now, opts.RequireOCSP); err != nil {
+			return nil, verificationFailure(FailureRevoked, err)
+		}
+	}
+	countersigned, err := countersignedSignature(js.signatures, signature)
+	if err != nil {
+		return nil, err
+	}
 
-			sigBytes, err := joseBase64UrlDecode(signature.Signature)
-			if err != nil {
-				return nil, err
-			}
+	sigBytes, err := joseBase64UrlDecode(signature.Signature)
+	if err != nil {
+		return nil, err
+	}
 
-			err = publicKey.Verify(bytes.NewReader(signBytes), signature.Header.Algorithm, sigBytes)
-			if err != nil {
-				return nil, err
-			}
+	err = publicKey.Verify(js.signingInput(signature.Protected, countersigned), signature.Header.Algorithm, sigBytes)
+	if err != nil {
+		return nil, verificationFailure(FailureInvalidSignature, err)
+	}
+
+	return verifiedChains, nil
+}
+
+// SignatureResult is the outcome of verifying a single signature.
+type SignatureResult struct {
+	// Index is the position of the signature in the JSONSignature.
+	Index int
+	// KeyID is the ID of the key which produced the signature, empty if
+	// the key could not be determined.
+	KeyID string
+	// PublicKey is the key used to sign, set when the signature is valid.
+	PublicKey PublicKey
+	// Chains are the verified x509 chains for the signature, only set when
+	// verifying chains.
+	Chains [][]*x509.Certificate
+	// Err is the reason verification failed, a *SignatureError, or nil if
+	// the signature is valid.
+	Err error
+}
+
+// VerifyParallel verifies all the signatures concurrently using at most
+// the given number of workers, or one per CPU if workers is not positive.
+// Unlike Verify, every signature is checked and a result is returned for
+// each one, in signature order. The returned error is that of the first
+// failed signature in signature order, regardless of which finished first.
+// Any x509 chains are not checked.
+func (js *JSONSignature) VerifyParallel(workers int) ([]SignatureResult, error) {
+	return js.verifyParallel(workers, func(signature *jsSignature, result *SignatureResult) {
+		result.PublicKey, result.Err = js.verifySignature(signature)
+	})
+}
+
+// VerifyChainsParallel verifies all the signatures and the chains associated
+// with each signature concurrently using at most the given number of workers,
+// or one per CPU if workers is not positive. A result is returned for each
+// signature, in signature order, and the returned error is that of the first
+// failed signature in signature order. Signatures without an x509 chain are
+// not checked and have no chains in their result.
+func (js *JSONSignature) VerifyChainsParallel(ca *x509.CertPool, workers int) ([]SignatureResult, error) {
+	return js.verifyParallel(workers, func(signature *jsSignature, result *SignatureResult) {
+		result.Chains, result.Err = js.verifySignatureChains(signature, &ChainVerifyOptions{Roots: ca})
+		if result.Err == nil && len(result.Chains) > 0 {
+			result.PublicKey, result.Err = FromCryptoPublicKey(result.Chains[0][0].PublicKey)
 		}
+	})
+}
 
+func (js *JSONSignature) verifyParallel(workers int, verify func(*jsSignature, *SignatureResult)) ([]SignatureResult, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
 	}
-	return chains, nil
+	if workers > len(js.signatures) {
+		workers = len(js.signatures)
+	}
+
+	results := make([]SignatureResult, len(js.signatures))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				signature := &js.signatures[i]
+				results[i].Index = i
+				results[i].KeyID = signature.keyID()
+				verify(signature, &results[i])
+				if results[i].Err != nil {
+					results[i].Err = js.signatureError(i, results[i].Err)
+				}
+			}
+		}()
+	}
+	for i := range results {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Err != nil {
+			return results, result.Err
+		}
+	}
+
+	return results, nil
 }
 
 // JWS returns JSON serialized JWS according to
@@ -313,6 +584,8 @@ type jsParsedHeader struct {
 	JWK       json.RawMessage `json:"jwk"`
 	Algorithm string          `json:"alg"`
 	Chain     []string        `json:"x5c"`
+	Timestamp json.RawMessage `json:"timestamp"`
+	OCSP      []string        `json:"ocsp"`
 }
 
 type jsParsedSignature struct {
@@ -321,11 +594,43 @@ type jsParsedSignature struct {
 	Protected string         `json:"protected"`
 }
 
+// jsSignature converts a parsed signature block into a signature,
+// unmarshalling the embedded public key if one is present.
+func (s *jsParsedSignature) jsSignature() (jsSignature, error) {
+	jsig := jsSignature{
+		Header: jsHeader{
+			Algorithm: s.Header.Algorithm,
+			Chain:     s.Header.Chain,
+			Timestamp: s.Header.Timestamp,
+			OCSP:      s.Header.OCSP,
+		},
+		Signature: s.Signature,
+		Protected: s.Protected,
+	}
+
+	if s.Header.JWK != nil {
+		publicKey, err := UnmarshalPublicKeyJWK([]byte(s.Header.JWK))
+		if err != nil {
+			return jsSignature{}, err
+		}
+		jsig.Header.JWK = publicKey
+	}
+
+	return jsig, nil
+}
+
 // ParseJWS parses a JWS serialized JSON object into a Json Signature.
 func ParseJWS(content []byte) (*JSONSignature, error) {
+	return parseJWS(content, nil)
+}
+
+func parseJWS(content []byte, opts *ParseOptions) (*JSONSignature, error) {
+	if err := opts.checkInput(content); err != nil {
+		return nil, err
+	}
 	type jsParsed struct {
-		Payload    string              `json:"payload"`
-		Signatures []jsParsedSignature `json:"signatures"`
+		Payload    string            `json:"payload"`
+		Signatures []json.RawMessage `json:"signatures"`
 	}
 	parsed := &jsParsed{}
 	err := json.Unmarshal(content, parsed)
@@ -335,34 +640,27 @@ func ParseJWS(content []byte) (*JSONSignature, error) {
 	if len(parsed.Signatures) == 0 {
 		return nil, errors.New("missing signatures")
 	}
+	if err := opts.checkSignatureCount(len(parsed.Signatures)); err != nil {
+		return nil, err
+	}
 	payload, err := joseBase64UrlDecode(parsed.Payload)
 	if err != nil {
 		return nil, err
 	}
 
-	js, err := NewJSONSignature(payload)
+	js, err := newJSONSignatureFromContent(payload, opts, nil)
 	if err != nil {
 		return nil, err
 	}
 	js.signatures = make([]jsSignature, len(parsed.Signatures))
-	for i, signature := range parsed.Signatures {
-		header := jsHeader{
-			Algorithm: signature.Header.Algorithm,
-		}
-		if signature.Header.Chain != nil {
-			header.Chain = signature.Header.Chain
-		}
-		if signature.Header.JWK != nil {
-			publicKey, err := UnmarshalPublicKeyJWK([]byte(signature.Header.JWK))
-			if err != nil {
-				return nil, err
-			}
-			header.JWK = publicKey
+	for i, signatureBlock := range parsed.Signatures {
+		signature, err := opts.parseSignatureBlock(signatureBlock)
+		if err != nil {
+			return nil, err
 		}
-		js.signatures[i] = jsSignature{
-			Header:    header,
-			Signature: signature.Signature,
-			Protected: signature.Protected,
+		js.signatures[i], err = signature.jsSignature()
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -376,6 +674,13 @@ func ParseJWS(content []byte) (*JSONSignature, error) {
 // package. It is the callers responsibility to ensure uniqueness of the
 // provided signatures.
 func NewJSONSignature(content []byte, signatures ...[]byte) (*JSONSignature, error) {
+	return newJSONSignatureFromContent(content, nil, signatures)
+}
+
+func newJSONSignatureFromContent(content []byte, opts *ParseOptions, signatures [][]byte) (*JSONSignature, error) {
+	if err := opts.checkPayload(content); err != nil {
+		return nil, err
+	}
 	var dataMap map[string]interface{}
 	err := json.Unmarshal(content, &dataMap)
 	if err != nil {
@@ -399,53 +704,98 @@ func NewJSONSignature(content []byte, signatures ...[]byte) (*JSONSignature, err
 	js.formatLength = lastRuneIndex + 1
 	js.formatTail = content[js.formatLength:]
 
-	if len(signatures) > 0 {
-		for _, signature := range signatures {
-			var parsedJSig jsParsedSignature
-
-			if err := json.Unmarshal(signature, &parsedJSig); err != nil {
-				return nil, err
-			}
+	if err := js.addSignatureBlobs(signatures, opts); err != nil {
+		return nil, err
+	}
 
-			// TODO(stevvooe): A lot of the code below is repeated in
-			// ParseJWS. It will require more refactoring to fix that.
-			jsig := jsSignature{
-				Header: jsHeader{
-					Algorithm: parsedJSig.Header.Algorithm,
-				},
-				Signature: parsedJSig.Signature,
-				Protected: parsedJSig.Protected,
-			}
+	return js, nil
+}
 
-			if parsedJSig.Header.Chain != nil {
-				jsig.Header.Chain = parsedJSig.Header.Chain
-			}
+// NewJSONSignatureCanonical returns a new unsigned JWS over the canonical
+// form of a json object or array, as returned by CanonicalJSON. Signatures made in
+// this mode verify against any document with the same content, whatever
+// its formatting. Optionally, one or more signatures can be provided as
+// byte buffers to assemble a fully signed JWS package, as with
+// NewJSONSignature.
+func NewJSONSignatureCanonical(content []byte, signatures ...[]byte) (*JSONSignature, error) {
+	value, err := decodeCanonical(content)
+	if err != nil {
+		return nil, err
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return nil, ErrInvalidJSONContent
+	}
 
-			if parsedJSig.Header.JWK != nil {
-				publicKey, err := UnmarshalPublicKeyJWK([]byte(parsedJSig.Header.JWK))
-				if err != nil {
-					return nil, err
-				}
-				jsig.Header.JWK = publicKey
-			}
+	js := newJSONSignature()
+	if err := js.setCanonicalPayload(value); err != nil {
+		return nil, err
+	}
 
-			js.signatures = append(js.signatures, jsig)
-		}
+	if err := js.addSignatureBlobs(signatures, nil); err != nil {
+		return nil, err
 	}
 
 	return js, nil
 }
 
-// NewJSONSignatureFromMap returns a new unsigned JSONSignature from a map or
-// struct. JWS will need to be signed before serializing or storing.
-func NewJSONSignatureFromMap(content interface{}) (*JSONSignature, error) {
-	switch content.(type) {
-	case map[string]interface{}:
-	case struct{}:
-	default:
-		return nil, errors.New("invalid data type")
+// ToCanonical returns a new unsigned JWS over the canonical form of the
+// payload of the receiver. The existing signatures cover the formatted
+// payload and cannot be carried over, so the returned JSONSignature must be
+// signed again by each signer.
+func (js *JSONSignature) ToCanonical() (*JSONSignature, error) {
+	payload, err := js.Payload()
+	if err != nil {
+		return nil, err
 	}
+	return NewJSONSignatureCanonical(payload)
+}
+
+// setCanonicalPayload sets the payload to the canonical form of the given
+// decoded json object. The canonical form has no whitespace so the format
+// tail is always the closing brace.
+func (js *JSONSignature) setCanonicalPayload(value interface{}) error {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, value); err != nil {
+		return err
+	}
+	payload := buf.Bytes()
+
+	js.canonical = true
+	js.indent = ""
+	js.payload = joseBase64UrlEncode(payload)
+	js.formatLength = len(payload) - 1
+	js.formatTail = payload[js.formatLength:]
+
+	return nil
+}
+
+// addSignatureBlobs adds signatures serialized as returned by Signatures.
+func (js *JSONSignature) addSignatureBlobs(signatures [][]byte, opts *ParseOptions) error {
+	for _, signature := range signatures {
+		parsedJSig, err := opts.parseSignatureBlock(signature)
+		if err != nil {
+			return err
+		}
 
+		jsig, err := parsedJSig.jsSignature()
+		if err != nil {
+			return err
+		}
+
+		js.signatures = append(js.signatures, jsig)
+	}
+
+	return nil
+}
+
+// NewJSONSignatureFromMap returns a new unsigned JSONSignature from a map,
+// struct or any other value which marshals to a json object or array. JWS
+// will need to be signed before serializing or storing. The signatures of
+// an array must be embedded within one of its elements, using
+// SetSignatureLocation.
+func NewJSONSignatureFromMap(content interface{}) (*JSONSignature, error) {
 	js := newJSONSignature()
 	js.indent = "   "
 
@@ -455,13 +805,55 @@ func NewJSONSignatureFromMap(content interface{}) (*JSONSignature, error) {
 	}
 	js.payload = joseBase64UrlEncode(payload)
 
-	// Remove '\n}' from formatted section, put in protected header
-	js.formatLength = len(payload) - 2
-	js.formatTail = payload[js.formatLength:]
+	switch payload[0] {
+	case '{':
+		// Remove '\n}' from formatted section, put in protected header
+		if err := js.setSignatureParent(payload, nil); err != nil {
+			return nil, err
+		}
+	case '[':
+		// An array has no member to hold the signatures until a location
+		// is set.
+		js.formatLength = noSignatureLocation
+	default:
+		return nil, errors.New("invalid data type")
+	}
 
 	return js, nil
 }
 
+// SetSignatureLocation sets the JSON pointer, such as
+// "/metadata/signatures", of the member in which PrettySignatureAt will
+// embed the signatures. The signatures are placed at the top level of the
+// document by default. The location is covered by the signatures and must
+// be set before signing.
+func (js *JSONSignature) SetSignatureLocation(pointer string) error {
+	if len(js.signatures) > 0 {
+		return errors.New("signature location cannot be changed once signed")
+	}
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		return err
+	}
+	payload, err := js.Payload()
+	if err != nil {
+		return err
+	}
+	return js.setSignatureParent(payload, tokens[:len(tokens)-1])
+}
+
+// setSignatureParent sets the format information so that signatures are
+// embedded in the object of payload referenced by the given tokens.
+func (js *JSONSignature) setSignatureParent(payload []byte, tokens []string) error {
+	formatLength, err := objectInsertionIndex(payload, tokens)
+	if err != nil {
+		return err
+	}
+	js.formatLength = formatLength
+	js.formatTail = payload[formatLength:]
+	return nil
+}
+
 func readIntFromMap(key string, m map[string]interface{}) (int, bool) {
 	value, ok := m[key]
 	if !ok {
@@ -489,10 +881,34 @@ func readStringFromMap(key string, m map[string]interface{}) (v string, ok bool)
 // ParsePrettySignature parses a formatted signature into a
 // JSON signature. If the signatures are missing the format information
 // an error is thrown. The formatted signature must be created by
-// the same method as format signature.
+// the same method as format signature, unless the signatures were made
+// over the canonical form of the content in which case the document may
+// have been reformatted in any way.
 func ParsePrettySignature(content []byte, signatureKey string) (*JSONSignature, error) {
+	return parsePrettySignature(content, nil, signatureKey, nil)
+}
+
+// ParsePrettySignatureAt parses a formatted signature whose signatures
+// are embedded in the member at the given JSON pointer, as created by
+// PrettySignatureAt.
+func ParsePrettySignatureAt(content []byte, pointer string) (*JSONSignature, error) {
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrettySignature(content, tokens[:len(tokens)-1], tokens[len(tokens)-1], nil)
+}
+
+func parsePrettySignature(content []byte, parent []string, signatureKey string, opts *ParseOptions) (*JSONSignature, error) {
+	if err := opts.checkInput(content); err != nil {
+		return nil, err
+	}
+	parentMessage, err := lookupJSONPointer(content, parent)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling content: %s", err)
+	}
 	var contentMap map[string]json.RawMessage
-	err := json.Unmarshal(content, &contentMap)
+	err = json.Unmarshal(parentMessage, &contentMap)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling content: %s", err)
 	}
@@ -501,16 +917,24 @@ func ParsePrettySignature(content []byte, signatureKey string) (*JSONSignature,
 		return nil, ErrMissingSignatureKey
 	}
 
-	var signatureBlocks []jsParsedSignature
-	err = json.Unmarshal([]byte(sigMessage), &signatureBlocks)
+	var signatureMessages []json.RawMessage
+	err = json.Unmarshal([]byte(sigMessage), &signatureMessages)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling signatures: %s", err)
 	}
+	if err := opts.checkSignatureCount(len(signatureMessages)); err != nil {
+		return nil, err
+	}
 
 	js := newJSONSignature()
-	js.signatures = make([]jsSignature, len(signatureBlocks))
+	js.signatures = make([]jsSignature, len(signatureMessages))
 
-	for i, signatureBlock := range signatureBlocks {
+	var canonical string
+	for i, signatureMessage := range signatureMessages {
+		signatureBlock, err := opts.parseSignatureBlock(signatureMessage)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling signatures: %s", err)
+		}
 		protectedBytes, err := joseBase64UrlDecode(signatureBlock.Protected)
 		if err != nil {
 			return nil, fmt.Errorf("base64 decode error: %s", err)
@@ -521,6 +945,23 @@ func ParsePrettySignature(content []byte, signatureKey string) (*JSONSignature,
 			return nil, fmt.Errorf("error unmarshalling protected header: %s", err)
 		}
 
+		js.signatures[i], err = signatureBlock.jsSignature()
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling public key: %s", err)
+		}
+
+		mode, _ := readStringFromMap("canonical", protectedHeader)
+		if i == 0 {
+			canonical = mode
+		} else if mode != canonical {
+			return nil, errors.New("conflicting canonicalization")
+		}
+		if canonical == canonicalJCS {
+			continue
+		} else if canonical != "" {
+			return nil, fmt.Errorf("unsupported canonicalization %q", canonical)
+		}
+
 		formatLength, ok := readIntFromMap("formatLength", protectedHeader)
 		if !ok {
 			return nil, errors.New("missing formatted length")
@@ -543,54 +984,127 @@ func ParsePrettySignature(content []byte, signatureKey string) (*JSONSignature,
 		} else if bytes.Compare(js.formatTail, formatTail) != 0 {
 			return nil, errors.New("conflicting format tail")
 		}
+	}
 
-		header := jsHeader{
-			Algorithm: signatureBlock.Header.Algorithm,
-			Chain:     signatureBlock.Header.Chain,
+	if canonical != "" {
+		// The signed payload is the canonical form of the document
+		// without its signatures, however it has been formatted since.
+		value, err := decodeCanonical(content)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling content: %s", err)
 		}
-		if signatureBlock.Header.JWK != nil {
-			publicKey, err := UnmarshalPublicKeyJWK([]byte(signatureBlock.Header.JWK))
-			if err != nil {
-				return nil, fmt.Errorf("error unmarshalling public key: %s", err)
-			}
-			header.JWK = publicKey
+		object, err := canonicalParent(value, parent)
+		if err != nil {
+			return nil, err
+		}
+		delete(object, signatureKey)
+		if err := js.setCanonicalPayload(value); err != nil {
+			return nil, err
+		}
+		payload, err := js.Payload()
+		if err != nil {
+			return nil, err
 		}
-		js.signatures[i] = jsSignature{
-			Header:    header,
-			Signature: signatureBlock.Signature,
-			Protected: signatureBlock.Protected,
+		if err := opts.checkPayload(payload); err != nil {
+			return nil, err
 		}
+		if err := js.setSignatureParent(payload, parent); err != nil {
+			return nil, err
+		}
+		return js, nil
 	}
-	if js.formatLength > len(content) {
+
+	if js.formatLength < 0 || js.formatLength > len(content) {
 		return nil, errors.New("invalid format length")
 	}
 	formatted := make([]byte, js.formatLength+len(js.formatTail))
 	copy(formatted, content[:js.formatLength])
 	copy(formatted[js.formatLength:], js.formatTail)
+	if err := opts.checkPayload(formatted); err != nil {
+		return nil, err
+	}
+	if err := checkFormattedRest(content[js.formatLength:], signatureKey, sigMessage, js.formatTail); err != nil {
+		return nil, err
+	}
 	js.indent = detectJSONIndent(formatted)
 	js.payload = joseBase64UrlEncode(formatted)
 
+	if parent != nil {
+		if formatLength, err := objectInsertionIndex(formatted, parent); err != nil || formatLength != js.formatLength {
+			return nil, errors.New("signature location does not match format")
+		}
+	}
+
 	return js, nil
 }
 
+// checkFormattedRest checks that the content of a formatted signature
+// following its signed prefix is only the signatures member, holding the
+// given signatures, and the signed format tail. Content after the
+// signatures would otherwise be silently replaced by the tail in the
+// payload, hiding it from verification.
+func checkFormattedRest(rest []byte, signatureKey string, sigMessage, formatTail []byte) error {
+	errUnsigned := errors.New("formatted content does not match signed content")
+
+	rest = bytes.TrimLeftFunc(rest, unicode.IsSpace)
+	if len(rest) > 0 && rest[0] == ',' {
+		rest = bytes.TrimLeftFunc(rest[1:], unicode.IsSpace)
+	}
+	key := []byte(`"` + signatureKey + `"`)
+	if !bytes.HasPrefix(rest, key) {
+		return errUnsigned
+	}
+	rest = bytes.TrimLeftFunc(rest[len(key):], unicode.IsSpace)
+	if len(rest) == 0 || rest[0] != ':' {
+		return errUnsigned
+	}
+	rest = bytes.TrimLeftFunc(rest[1:], unicode.IsSpace)
+	if !bytes.HasPrefix(rest, sigMessage) {
+		return errUnsigned
+	}
+	if !bytes.Equal(bytes.TrimSpace(rest[len(sigMessage):]), bytes.TrimSpace(formatTail)) {
+		return errUnsigned
+	}
+	return nil
+}
+
 // PrettySignature formats a json signature into an easy to read
 // single json serialized object.
 func (js *JSONSignature) PrettySignature(signatureKey string) ([]byte, error) {
+	return js.prettySignature(nil, signatureKey)
+}
+
+// PrettySignatureAt formats a json signature into a single json serialized
+// object with the signatures embedded in the member at the given JSON
+// pointer, which must be within the location set by SetSignatureLocation.
+func (js *JSONSignature) PrettySignatureAt(pointer string) ([]byte, error) {
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return js.prettySignature(tokens[:len(tokens)-1], tokens[len(tokens)-1])
+}
+
+func (js *JSONSignature) prettySignature(parent []string, signatureKey string) ([]byte, error) {
 	if len(js.signatures) == 0 {
 		return nil, errors.New("no signatures")
 	}
-	payload, err := joseBase64UrlDecode(js.payload)
+	content, err := joseBase64UrlDecode(js.payload)
 	if err != nil {
 		return nil, err
 	}
-	payload = payload[:js.formatLength]
+	if formatLength, err := objectInsertionIndex(content, parent); err != nil || formatLength != js.formatLength {
+		return nil, errors.New("signatures were not made for this location")
+	}
+	payload := content[:js.formatLength]
+	prefix := strings.Repeat(js.indent, len(parent)+1)
 
 	sort.Sort(jsSignaturesSorted(js.signatures))
 
 	var marshalled []byte
 	var marshallErr error
 	if js.indent != "" {
-		marshalled, marshallErr = json.MarshalIndent(js.signatures, js.indent, js.indent)
+		marshalled, marshallErr = json.MarshalIndent(js.signatures, prefix, js.indent)
 	} else {
 		marshalled, marshallErr = json.Marshal(js.signatures)
 	}
@@ -600,22 +1114,26 @@ func (js *JSONSignature) PrettySignature(signatureKey string) ([]byte, error) {
 
 	buf := bytes.NewBuffer(make([]byte, 0, len(payload)+len(marshalled)+34))
 	buf.Write(payload)
-	buf.WriteByte(',')
+	// An empty object has no members to separate the signatures from.
+	if lastIndex := bytes.LastIndexFunc(payload, notSpace); lastIndex < 0 || payload[lastIndex] != '{' {
+		buf.WriteByte(',')
+	}
 	if js.indent != "" {
 		buf.WriteByte('\n')
-		buf.WriteString(js.indent)
+		buf.WriteString(prefix)
 		buf.WriteByte('"')
 		buf.WriteString(signatureKey)
 		buf.WriteString("\": ")
 		buf.Write(marshalled)
 		buf.WriteByte('\n')
+		buf.WriteString(prefix[len(js.indent):])
 	} else {
 		buf.WriteByte('"')
 		buf.WriteString(signatureKey)
 		buf.WriteString("\":")
 		buf.Write(marshalled)
 	}
-	buf.WriteByte('}')
+	buf.Write(bytes.TrimLeftFunc(content[js.formatLength:], unicode.IsSpace))
 
 	return buf.Bytes(), nil
 }
@@ -655,3 +1173,143 @@ func (js *JSONSignature) Merge(others ...*JSONSignature) error {
 	js.signatures = merged
 	return nil
 }
+
+// SignatureInfo describes a single signature of a JSONSignature.
+type SignatureInfo struct {
+	// Index is the position of the signature in the JSONSignature.
+	Index int
+	// KeyID is the ID of the key which produced the signature, empty if
+	// the key could not be determined.
+	KeyID string
+	// Algorithm is the signature algorithm from the signature header.
+	Algorithm string
+	// Countersigns is the ID of the key which made the signature covered
+	// by this countersignature, empty if this is not a countersignature.
+	Countersigns string
+	// Chain is the x509 chain of the signature, empty if the signature
+	// has no chain or the chain could not be parsed.
+	Chain []*x509.Certificate
+}
+
+// SignatureInfos returns a description of each signature, in signature
+// order.
+func (js *JSONSignature) SignatureInfos() []SignatureInfo {
+	infos := make([]SignatureInfo, len(js.signatures))
+	for i := range js.signatures {
+		infos[i] = js.signatureInfo(i)
+	}
+	return infos
+}
+
+func (js *JSONSignature) signatureInfo(index int) SignatureInfo {
+	s := &js.signatures[index]
+	info := SignatureInfo{
+		Index:     index,
+		KeyID:     s.keyID(),
+		Algorithm: s.Header.Algorithm,
+	}
+	if countersigned, err := countersignedSignature(js.signatures, s); err == nil && countersigned != nil {
+		info.Countersigns = countersigned.keyID()
+	}
+	if chain, err := s.chain(); err == nil && len(chain) > 0 {
+		info.Chain = chain
+	}
+	return info
+}
+
+// RemoveSignatures removes every signature for which match returns true and
+// returns the number of signatures removed. Countersignatures covering a
+// removed signature are removed as well. The payload and the remaining
+// signatures are left untouched.
+func (js *JSONSignature) RemoveSignatures(match func(SignatureInfo) bool) int {
+	kept := make([]jsSignature, 0, len(js.signatures))
+	for i := range js.signatures {
+		if !match(js.signatureInfo(i)) {
+			kept = append(kept, js.signatures[i])
+		}
+	}
+
+	// Drop countersignatures left without the signature they cover, which
+	// may in turn leave others without theirs.
+	for pruned := true; pruned; {
+		pruned = false
+		for i := 0; i < len(kept); i++ {
+			digest, err := kept[i].countersigns()
+			if err != nil || digest == "" {
+				continue
+			}
+			if _, err := countersignedSignature(kept, &kept[i]); err != nil {
+				kept = append(kept[:i], kept[i+1:]...)
+				pruned = true
+				i--
+			}
+		}
+	}
+
+	removed := len(js.signatures) - len(kept)
+	js.signatures = kept
+	return removed
+}
+
+// RemoveSignaturesByKeyID removes the signatures made by the key with the
+// given ID and returns the number of signatures removed.
+func (js *JSONSignature) RemoveSignaturesByKeyID(keyID string) int {
+	return js.RemoveSignatures(func(info SignatureInfo) bool {
+		return info.KeyID == keyID
+	})
+}
+
+// RemoveSignaturesByCertificate removes the signatures whose leaf
+// certificate has the given fingerprint, as returned by
+// CertificateFingerprint, and returns the number of signatures removed.
+func (js *JSONSignature) RemoveSignaturesByCertificate(fingerprint string) int {
+	return js.RemoveSignatures(func(info SignatureInfo) bool {
+		return len(info.Chain) > 0 && CertificateFingerprint(info.Chain[0]) == fingerprint
+	})
+}
+
+// ReplaceSignature removes the signatures made by the key with the given ID
+// and adds a fresh signature using the given private key, which may be the
+// same key or the one replacing it. It fails without signing if no
+// signature was made by the key with the given ID. The receiver is not
+// modified if signing fails.
+func (js *JSONSignature) ReplaceSignature(keyID string, key PrivateKey) error {
+	return js.replaceSignature(keyID, func() error {
+		return js.Sign(key)
+	})
+}
+
+// ReplaceSignatureWithChain removes the signatures made by the key with the
+// given ID and adds a fresh signature using the given private key and x509
+// chain. It fails without signing if no signature was made by the key with
+// the given ID. The receiver is not modified if signing fails.
+func (js *JSONSignature) ReplaceSignatureWithChain(keyID string, key PrivateKey, chain []*x509.Certificate) error {
+	return js.replaceSignature(keyID, func() error {
+		return js.SignWithChain(key, chain)
+	})
+}
+
+func (js *JSONSignature) replaceSignature(keyID string, sign func() error) error {
+	found := false
+	for i := range js.signatures {
+		if js.signatures[i].keyID() == keyID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("no signature by key %q", keyID)
+	}
+
+	n := len(js.signatures)
+	if err := sign(); err != nil {
+		return err
+	}
+	fresh := js.signatures[n:]
+	js.signatures = js.signatures[:n:n]
+
+	js.RemoveSignaturesByKeyID(keyID)
+	js.signatures = append(js.signatures, fresh...)
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package libtrust
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+)
+
+// digestSigner is implemented by private keys which are able to sign an
+// already computed hash, allowing the data to be hashed only once for
+// several keys.
+type digestSigner interface {
+	signatureAlgorithmForHash(hashID crypto.Hash) *signatureAlgorithm
+	signDigest(sigAlg *signatureAlgorithm, hash []byte) ([]byte, error)
+}
+
+// digestVerifier is implemented by public keys which are able to verify a
+// signature against an already computed hash.
+type digestVerifier interface {
+	verifyDigest(sigAlg *signatureAlgorithm, hash, signature []byte) error
+}
+
+// SignJSONStream signs the JSON object read from payload with each of the
+// given keys without holding the payload in memory. The payload is hashed
+// incrementally and only once for all keys which share a hash algorithm.
+// The signatures are returned as opaque blobs, sorted by keyID, in the same
+// form as JSONSignature.Signatures and may be assembled with the payload
+// using NewJSONSignature.
+//
+// The payload must be seekable since the protected header, which precedes
+// the payload in the signing input, records the formatting at the end of
+// the payload.
+func SignJSONStream(payload io.ReadSeeker, keys ...PrivateKey) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("missing signing keys")
+	}
+
+	formatLength, formatTail, err := readFormatTail(payload)
+	if err != nil {
+		return nil, err
+	}
+	js := &JSONSignature{
+		formatLength: formatLength,
+		formatTail:   formatTail,
+	}
+	protected, err := js.protectedHeader(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	signers := make([]digestSigner, len(keys))
+	sigAlgs := make([]*signatureAlgorithm, len(keys))
+	hashers := map[crypto.Hash]hash.Hash{}
+	var writers []io.Writer
+	for i, key := range keys {
+		signer, ok := key.(digestSigner)
+		if !ok {
+			return nil, fmt.Errorf("private key type %T does not support stream signing", key)
+		}
+		signers[i] = signer
+		sigAlgs[i] = signer.signatureAlgorithmForHash(crypto.SHA256)
+
+		hashID := sigAlgs[i].HashID()
+		if _, ok := hashers[hashID]; !ok {
+			hasher := hashID.New()
+			io.WriteString(hasher, protected+".")
+			hashers[hashID] = hasher
+			writers = append(writers, hasher)
+		}
+	}
+
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := hashJSONPayload(payload, io.MultiWriter(writers...)); err != nil {
+		return nil, err
+	}
+
+	signatures := make([]jsSignature, len(keys))
+	for i, key := range keys {
+		sigBytes, err := signers[i].signDigest(sigAlgs[i], hashers[sigAlgs[i].HashID()].Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+		signatures[i] = jsSignature{
+			Header: jsHeader{
+				JWK:       key.PublicKey(),
+				Algorithm: sigAlgs[i].HeaderParam(),
+			},
+			Signature: joseBase64UrlEncode(sigBytes),
+			Protected: protected,
+		}
+	}
+
+	sort.Sort(jsSignaturesSorted(signatures))
+
+	sb := make([][]byte, len(signatures))
+	for i, jsig := range signatures {
+		sb[i], err = json.Marshal(jsig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sb, nil
+}
+
+// VerifyJSONStream verifies the given signatures, as returned by
+// SignJSONStream or JSONSignature.Signatures, against the JSON object read
+// from payload and returns the list of public keys used to sign. The payload
+// is read once and hashed only once for all signatures which share a
+// protected header and hash algorithm. Countersignatures are verified
+// against the signature they cover, which must be among the given
+// signatures. Any x509 chains are not checked.
+func VerifyJSONStream(payload io.Reader, signatures ...[]byte) ([]PublicKey, error) {
+	if len(signatures) == 0 {
+		return nil, errors.New("missing signatures")
+	}
+
+	type digestKey struct {
+		protected string
+		hashID    crypto.Hash
+	}
+
+	jsigs := make([]jsSignature, len(signatures))
+	for i, signature := range signatures {
+		var parsedJSig jsParsedSignature
+		if err := json.Unmarshal(signature, &parsedJSig); err != nil {
+			return nil, err
+		}
+		jsig, err := parsedJSig.jsSignature()
+		if err != nil {
+			return nil, err
+		}
+		jsigs[i] = jsig
+	}
+
+	sigAlgs := make([]*signatureAlgorithm, len(jsigs))
+	hashers := map[digestKey]hash.Hash{}
+	// The protected header of a countersignature names the signature it
+	// covers so signatures sharing a hasher also share that signature.
+	countersigned := map[digestKey]*jsSignature{}
+	var writers []io.Writer
+	for i := range jsigs {
+		sigAlg, err := signatureAlgorithmByName(jsigs[i].Header.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		sigAlgs[i] = sigAlg
+
+		dk := digestKey{jsigs[i].Protected, sigAlg.HashID()}
+		if _, ok := hashers[dk]; !ok {
+			hasher := sigAlg.HashID().New()
+			io.WriteString(hasher, jsigs[i].Protected+".")
+			hashers[dk] = hasher
+			writers = append(writers, hasher)
+
+			countersigned[dk], err = countersignedSignature(jsigs, &jsigs[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := hashJSONPayload(payload, io.MultiWriter(writers...)); err != nil {
+		return nil, err
+	}
+	for dk, target := range countersigned {
+		if target != nil {
+			io.WriteString(hashers[dk], target.countersignSuffix())
+		}
+	}
+
+	keys := make([]PublicKey, len(jsigs))
+	for i, jsig := range jsigs {
+		publicKey, err := jsig.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		verifier, ok := publicKey.(digestVerifier)
+		if !ok {
+			return nil, fmt.Errorf("public key type %T does not support stream verification", publicKey)
+		}
+
+		sigBytes, err := joseBase64UrlDecode(jsig.Signature)
+		if err != nil {
+			return nil, err
+		}
+
+		dk := digestKey{jsig.Protected, sigAlgs[i].HashID()}
+		if err := verifier.verifyDigest(sigAlgs[i], hashers[dk].Sum(nil), sigBytes); err != nil {
+			return nil, err
+		}
+
+		keys[i] = publicKey
+	}
+
+	return keys, nil
+}
+
+// hashJSONPayload writes the base64url encoding of the payload read from r
+// to w, checking along the way that the payload is a single JSON object.
+func hashJSONPayload(r io.Reader, w io.Writer) error {
+	encoder := base64.NewEncoder(base64.RawURLEncoding, w)
+	dec := json.NewDecoder(io.TeeReader(r, encoder))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return ErrInvalidJSONContent
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	// Reading up to EOF also passes any trailing whitespace to the encoder.
+	if _, err := dec.Token(); err != io.EOF {
+		return ErrInvalidJSONContent
+	}
+
+	return encoder.Close()
+}
+
+// readFormatTail finds the format length and tail of the JSON object read
+// from r, as computed by NewJSONSignature, reading only the end of the
+// content.
+func readFormatTail(r io.ReadSeeker) (int, []byte, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for window := int64(512); ; window *= 2 {
+		start := size - window
+		if start < 0 {
+			start = 0
+		}
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return 0, nil, err
+		}
+		content := make([]byte, size-start)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return 0, nil, err
+		}
+
+		closeIndex := bytes.LastIndexFunc(content, notSpace)
+		if closeIndex >= 0 {
+			if content[closeIndex] != '}' {
+				return 0, nil, ErrInvalidJSONContent
+			}
+			lastRuneIndex := bytes.LastIndexFunc(content[:closeIndex], notSpace)
+			if lastRuneIndex >= 0 {
+				if content[lastRuneIndex] == ',' {
+					return 0, nil, ErrInvalidJSONContent
+				}
+				return int(start) + lastRuneIndex + 1, content[lastRuneIndex+1:], nil
+			}
+		}
+
+		if start == 0 {
+			return 0, nil, ErrInvalidJSONContent
+		}
+	}
+}
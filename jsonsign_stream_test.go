@@ -0,0 +1,152 @@
+package libtrust
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSignJSONStream(t *testing.T) {
+	ecKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ec384Key, err := GenerateECP384PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	rsaKey, err := GenerateRSA2048PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %s", err)
+	}
+
+	testMap, _ := createTestJSON("buildSignatures", "   ")
+	indented, err := json.MarshalIndent(testMap, "", "   ")
+	if err != nil {
+		t.Fatalf("Marshall error: %s", err)
+	}
+	indented = append(indented, '\n')
+
+	signatures, err := SignJSONStream(bytes.NewReader(indented), ecKey, ec384Key, rsaKey)
+	if err != nil {
+		t.Fatalf("Error signing stream: %s", err)
+	}
+	if len(signatures) != 3 {
+		t.Fatalf("Unexpected number of signatures: %d", len(signatures))
+	}
+
+	keys, err := VerifyJSONStream(bytes.NewReader(indented), signatures...)
+	if err != nil {
+		t.Fatalf("Error verifying stream: %s", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("Error wrong number of keys returned")
+	}
+
+	// Signatures assembled with the payload must match an in memory
+	// signature of the same content.
+	js, err := NewJSONSignature(indented, signatures...)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if _, err := js.Verify(); err != nil {
+		t.Fatalf("Error verifying assembled signature: %s", err)
+	}
+	b, err := js.PrettySignature("buildSignatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	parsed, err := ParsePrettySignature(b, "buildSignatures")
+	if err != nil {
+		t.Fatalf("Error parsing formatted signature: %s", err)
+	}
+	if _, err := parsed.Verify(); err != nil {
+		t.Fatalf("Error verifying parsed signature: %s", err)
+	}
+
+	tampered := bytes.Replace(indented, []byte("dmcgowan"), []byte("dmcgowen"), 1)
+	if _, err := VerifyJSONStream(bytes.NewReader(tampered), signatures...); err == nil {
+		t.Fatalf("Expected error verifying tampered payload")
+	}
+}
+
+func TestVerifyJSONStreamInMemorySignature(t *testing.T) {
+	key1, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	key2, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	testMap, _ := createTestJSON("buildSignatures", "")
+	content, err := json.Marshal(testMap)
+	if err != nil {
+		t.Fatalf("Marshall error: %s", err)
+	}
+
+	js, err := NewJSONSignature(content)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.Sign(key1); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	if err := js.Sign(key2); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	signatures, err := js.Signatures()
+	if err != nil {
+		t.Fatalf("Error getting signatures: %s", err)
+	}
+
+	keys, err := VerifyJSONStream(bytes.NewReader(content), signatures...)
+	if err != nil {
+		t.Fatalf("Error verifying stream: %s", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Error wrong number of keys returned")
+	}
+}
+
+func TestSignJSONStreamInvalidContent(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	for _, content := range []string{
+		"",
+		"   ",
+		"[1, 2]",
+		`{"a": 1,}`,
+		`{"a": 1} {"b": 2}`,
+		`{"a": [1}`,
+	} {
+		if _, err := SignJSONStream(bytes.NewReader([]byte(content)), key); err == nil {
+			t.Fatalf("Expected error signing invalid content %q", content)
+		}
+	}
+}
+
+func TestReadFormatTail(t *testing.T) {
+	content := append([]byte(`{"a": "b"`), bytes.Repeat([]byte(" \n"), 1000)...)
+	content = append(content, "}\n\n"...)
+
+	formatLength, formatTail, err := readFormatTail(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Error reading format tail: %s", err)
+	}
+
+	js, err := NewJSONSignature(content)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if formatLength != js.formatLength {
+		t.Fatalf("Unexpected format length %d, expected %d", formatLength, js.formatLength)
+	}
+	if !bytes.Equal(formatTail, js.formatTail) {
+		t.Fatalf("Unexpected format tail %q, expected %q", formatTail, js.formatTail)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/docker/libtrust/testutil"
@@ -378,3 +379,645 @@ func TestMergeSignatures(t *testing.T) {
 		t.Fatalf("error expected during invalid merge with different payload")
 	}
 }
+
+func TestVerifyParallel(t *testing.T) {
+	testMap, _ := createTestJSON("buildSignatures", "   ")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+
+	keys := make([]PrivateKey, 8)
+	for i := range keys {
+		keys[i], err = GenerateECP256PrivateKey()
+		if err != nil {
+			t.Fatalf("Error generating EC key: %s", err)
+		}
+		if err := js.Sign(keys[i]); err != nil {
+			t.Fatalf("Error signing content: %s", err)
+		}
+	}
+
+	results, err := js.VerifyParallel(3)
+	if err != nil {
+		t.Fatalf("Error verifying signatures: %s", err)
+	}
+	if len(results) != len(keys) {
+		t.Fatalf("Unexpected number of results: %d", len(results))
+	}
+	for i, result := range results {
+		if result.Index != i {
+			t.Fatalf("Unexpected result index %d at position %d", result.Index, i)
+		}
+		if result.Err != nil || result.PublicKey == nil {
+			t.Fatalf("Unexpected result for signature %d: %v", i, result.Err)
+		}
+		if result.KeyID != keys[i].KeyID() || result.PublicKey.KeyID() != keys[i].KeyID() {
+			t.Fatalf("Unexpected key for signature %d", i)
+		}
+	}
+
+	// Break two signatures, the error returned must always be for the
+	// first of them.
+	js.signatures[2].Signature = js.signatures[0].Signature
+	js.signatures[6].Signature = js.signatures[0].Signature
+	for n := 0; n < 10; n++ {
+		results, err = js.VerifyParallel(0)
+		if err == nil {
+			t.Fatalf("Expected error verifying invalid signatures")
+		}
+		if !strings.Contains(err.Error(), fmt.Sprintf("signature 2 by key %q", keys[2].KeyID())) {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		for i, result := range results {
+			if failed := i == 2 || i == 6; failed != (result.Err != nil) {
+				t.Fatalf("Unexpected result for signature %d: %v", i, result.Err)
+			}
+		}
+	}
+}
+
+func TestVerifyChainsParallel(t *testing.T) {
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating ca: %s", err)
+	}
+
+	testMap, _ := createTestJSON("verifySignatures", "   ")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSONSignature from map: %s", err)
+	}
+
+	trustKey1, chain1 := generateTrustChain(t, caKey, ca)
+	if err := js.SignWithChain(trustKey1, chain1); err != nil {
+		t.Fatalf("Error signing with chain: %s", err)
+	}
+	trustKey2, chain2 := generateTrustChain(t, caKey, ca)
+	if err := js.SignWithChain(trustKey2, chain2[:3]); err != nil {
+		t.Fatalf("Error signing with chain: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	results, err := js.VerifyChainsParallel(pool, 2)
+	if err == nil {
+		t.Fatalf("Expected error verifying with bad chain")
+	}
+	if len(results) != 2 {
+		t.Fatalf("Unexpected number of results: %d", len(results))
+	}
+	if results[0].Err != nil || len(results[0].Chains) != 1 || len(results[0].Chains[0]) != 7 {
+		t.Fatalf("Unexpected result for valid chain: %v", results[0].Err)
+	}
+	if results[0].KeyID != trustKey1.KeyID() {
+		t.Fatalf("Unexpected key for valid chain")
+	}
+	if results[1].Err == nil || len(results[1].Chains) != 0 {
+		t.Fatalf("Expected error for invalid chain")
+	}
+}
+
+func TestRemoveAndReplaceSignatures(t *testing.T) {
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating ca: %s", err)
+	}
+
+	testMap, _ := createTestJSON("buildSignatures", "   ")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+
+	keys := make([]PrivateKey, 3)
+	for i := range keys {
+		keys[i], err = GenerateECP256PrivateKey()
+		if err != nil {
+			t.Fatalf("Error generating EC key: %s", err)
+		}
+		if err := js.Sign(keys[i]); err != nil {
+			t.Fatalf("Error signing content: %s", err)
+		}
+	}
+	chainKey, chain := generateTrustChain(t, caKey, ca)
+	if err := js.SignWithChain(chainKey, chain); err != nil {
+		t.Fatalf("Error signing with chain: %s", err)
+	}
+
+	original, err := js.PrettySignature("buildSignatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+
+	if n := js.RemoveSignaturesByKeyID(keys[1].KeyID()); n != 1 {
+		t.Fatalf("Unexpected number of signatures removed: %d", n)
+	}
+	if n := js.RemoveSignaturesByCertificate(CertificateFingerprint(chain[0])); n != 1 {
+		t.Fatalf("Unexpected number of signatures removed: %d", n)
+	}
+	if n := js.RemoveSignaturesByKeyID(keys[1].KeyID()); n != 0 {
+		t.Fatalf("Unexpected number of signatures removed: %d", n)
+	}
+
+	removed, err := js.PrettySignature("buildSignatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	parsed, err := ParsePrettySignature(removed, "buildSignatures")
+	if err != nil {
+		t.Fatalf("Error parsing formatted signature: %s", err)
+	}
+	verified, err := parsed.Verify()
+	if err != nil {
+		t.Fatalf("Error verifying signature: %s", err)
+	}
+	if len(verified) != 2 {
+		t.Fatalf("Unexpected number of signatures: %d", len(verified))
+	}
+
+	// The remaining signatures must be byte for byte identical to the
+	// ones in the original document.
+	originalParsed, err := ParsePrettySignature(original, "buildSignatures")
+	if err != nil {
+		t.Fatalf("Error parsing formatted signature: %s", err)
+	}
+	originalParsed.RemoveSignatures(func(info SignatureInfo) bool {
+		return info.KeyID == keys[1].KeyID() || len(info.Chain) > 0
+	})
+	rebuilt, err := originalParsed.PrettySignature("buildSignatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	if !bytes.Equal(rebuilt, removed) {
+		t.Fatalf("Unexpected formatted signature after removal\nExpected:\n%s\nActual:\n%s", removed, rebuilt)
+	}
+
+	// Rotate the first signer out for a new key.
+	rotated, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	if err := parsed.ReplaceSignature(keys[0].KeyID(), rotated); err != nil {
+		t.Fatalf("Error replacing signature: %s", err)
+	}
+	infos := parsed.SignatureInfos()
+	if len(infos) != 2 {
+		t.Fatalf("Unexpected number of signatures: %d", len(infos))
+	}
+	found := map[string]bool{}
+	for _, info := range infos {
+		found[info.KeyID] = true
+	}
+	if !found[rotated.KeyID()] || !found[keys[2].KeyID()] || found[keys[0].KeyID()] {
+		t.Fatalf("Unexpected signers after replacement: %v", found)
+	}
+	if _, err := parsed.Verify(); err != nil {
+		t.Fatalf("Error verifying signature: %s", err)
+	}
+
+	// Replacing a signature which does not exist fails without signing.
+	if err := parsed.ReplaceSignature(keys[0].KeyID(), keys[0]); err == nil {
+		t.Fatalf("Expected error replacing missing signature")
+	}
+	if len(parsed.SignatureInfos()) != 2 {
+		t.Fatalf("Unexpected signature added when replacing missing signature")
+	}
+}
+
+func TestCountersign(t *testing.T) {
+	builder, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	notary, err := GenerateRSA2048PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %s", err)
+	}
+
+	testMap, _ := createTestJSON("buildSignatures", "   ")
+	indented, err := json.MarshalIndent(testMap, "", "   ")
+	if err != nil {
+		t.Fatalf("Marshall error: %s", err)
+	}
+	js, err := NewJSONSignature(indented)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.Sign(builder); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	if err := js.Countersign(notary, builder.KeyID()); err != nil {
+		t.Fatalf("Error countersigning: %s", err)
+	}
+	if err := js.Countersign(notary, "missing"); err == nil {
+		t.Fatalf("Expected error countersigning missing signature")
+	}
+
+	b, err := js.PrettySignature("buildSignatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	parsed, err := ParsePrettySignature(b, "buildSignatures")
+	if err != nil {
+		t.Fatalf("Error parsing formatted signature: %s", err)
+	}
+	keys, err := parsed.Verify()
+	if err != nil {
+		t.Fatalf("Error verifying signature: %s", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Unexpected number of keys: %d", len(keys))
+	}
+	for _, info := range parsed.SignatureInfos() {
+		if info.KeyID == notary.KeyID() && info.Countersigns != builder.KeyID() {
+			t.Fatalf("Unexpected countersigned key: %q", info.Countersigns)
+		}
+		if info.KeyID == builder.KeyID() && info.Countersigns != "" {
+			t.Fatalf("Unexpected countersignature by builder")
+		}
+	}
+
+	signatures, err := parsed.Signatures()
+	if err != nil {
+		t.Fatalf("Error getting signatures: %s", err)
+	}
+	if _, err := VerifyJSONStream(bytes.NewReader(indented), signatures...); err != nil {
+		t.Fatalf("Error verifying stream: %s", err)
+	}
+	// The countersignature alone cannot be verified.
+	for _, signature := range signatures {
+		if !bytes.Contains(signature, []byte(notary.KeyID())) {
+			continue
+		}
+		if _, err := VerifyJSONStream(bytes.NewReader(indented), signature); err == nil {
+			t.Fatalf("Expected error verifying countersignature without the signature it covers")
+		}
+	}
+
+	// A new signature by the builder is not covered by the countersignature.
+	resigned, err := ParsePrettySignature(b, "buildSignatures")
+	if err != nil {
+		t.Fatalf("Error parsing formatted signature: %s", err)
+	}
+	n := len(resigned.signatures)
+	if err := resigned.Sign(builder); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	for i := 0; i < n; i++ {
+		if resigned.signatures[i].keyID() == builder.KeyID() {
+			resigned.signatures[i] = resigned.signatures[n]
+		}
+	}
+	resigned.signatures = resigned.signatures[:n]
+	if _, err := resigned.Verify(); err == nil {
+		t.Fatalf("Expected error verifying countersignature over a different signature")
+	}
+
+	// Removing the builder signature also removes the countersignature.
+	if n := parsed.RemoveSignaturesByKeyID(builder.KeyID()); n != 2 {
+		t.Fatalf("Unexpected number of signatures removed: %d", n)
+	}
+}
+
+func TestCanonicalSignature(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	content := []byte(`{"name": "dmcgowan/mycontainer", "layers": [1e2, "aé"], "config": {"b": true, "a": null}}`)
+	js, err := NewJSONSignatureCanonical(content)
+	if err != nil {
+		t.Fatalf("Error creating canonical signature: %s", err)
+	}
+	if err := js.Sign(key); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+
+	payload, err := js.Payload()
+	if err != nil {
+		t.Fatalf("Error getting payload: %s", err)
+	}
+	expected := `{"config":{"a":null,"b":true},"layers":[100,"aé"],"name":"dmcgowan/mycontainer"}`
+	if string(payload) != expected {
+		t.Fatalf("Unexpected payload\n\tExpected: %s\n\tActual: %s", expected, payload)
+	}
+
+	pretty, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+
+	// Reformat the signed document with reordered members and new
+	// indentation, which must not invalidate the signature.
+	var document map[string]interface{}
+	if err := json.Unmarshal(pretty, &document); err != nil {
+		t.Fatalf("Error unmarshalling pretty signature: %s", err)
+	}
+	reformatted, err := json.MarshalIndent(document, "", "\t")
+	if err != nil {
+		t.Fatalf("Error reformatting document: %s", err)
+	}
+	if bytes.Equal(reformatted, pretty) {
+		t.Fatalf("Expected reformatted document to differ")
+	}
+
+	parsed, err := ParsePrettySignature(reformatted, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing reformatted signature: %s", err)
+	}
+	keys, err := parsed.Verify()
+	if err != nil {
+		t.Fatalf("Error verifying reformatted signature: %s", err)
+	}
+	if len(keys) != 1 || keys[0].KeyID() != key.KeyID() {
+		t.Fatalf("Unexpected verified keys: %v", keys)
+	}
+
+	// Changing the content must still be detected.
+	document["name"] = "dmcgowan/othercontainer"
+	tampered, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("Error marshalling tampered document: %s", err)
+	}
+	parsed, err = ParsePrettySignature(tampered, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing tampered signature: %s", err)
+	}
+	if _, err := parsed.Verify(); err == nil {
+		t.Fatalf("Expected tampered document to fail verification")
+	}
+}
+
+func TestToCanonical(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	testMap, _ := createTestJSON("", "")
+	content, err := json.MarshalIndent(testMap, "", "   ")
+	if err != nil {
+		t.Fatalf("Error marshalling test map: %s", err)
+	}
+	js, err := NewJSONSignature(content)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.Sign(key); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+
+	canonical, err := js.ToCanonical()
+	if err != nil {
+		t.Fatalf("Error converting to canonical form: %s", err)
+	}
+	if len(canonical.signatures) != 0 {
+		t.Fatalf("Expected converted signature to be unsigned, got %d signatures", len(canonical.signatures))
+	}
+	if err := canonical.Sign(key); err != nil {
+		t.Fatalf("Error signing canonical content: %s", err)
+	}
+
+	pretty, err := canonical.PrettySignature("signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	parsed, err := ParsePrettySignature(pretty, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing signature: %s", err)
+	}
+	if _, err := parsed.Verify(); err != nil {
+		t.Fatalf("Error verifying canonical signature: %s", err)
+	}
+}
+
+func TestPrettySignatureEmptyObject(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	js, err := NewJSONSignature([]byte("{}"))
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.Sign(key); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	pretty, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(pretty, &document); err != nil {
+		t.Fatalf("Invalid pretty signature %s: %s", pretty, err)
+	}
+	parsed, err := ParsePrettySignature(pretty, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing signature: %s", err)
+	}
+	if _, err := parsed.Verify(); err != nil {
+		t.Fatalf("Error verifying signature: %s", err)
+	}
+}
+
+type testManifest struct {
+	Name     string `json:"name"`
+	Layers   []string
+	Metadata struct {
+		Created string `json:"created"`
+	} `json:"metadata"`
+}
+
+func TestSignStructAtLocation(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	manifest := testManifest{Name: "dmcgowan/mycontainer", Layers: []string{"a", "b"}}
+	manifest.Metadata.Created = "2014-08-26T00:00:00Z"
+
+	js, err := NewJSONSignatureFromMap(manifest)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SetSignatureLocation("/metadata/signatures"); err != nil {
+		t.Fatalf("Error setting signature location: %s", err)
+	}
+	if err := js.Sign(key); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	if err := js.SetSignatureLocation("/signatures"); err == nil {
+		t.Fatalf("Expected error moving signatures after signing")
+	}
+	if _, err := js.PrettySignature("signatures"); err == nil {
+		t.Fatalf("Expected error embedding signatures at another location")
+	}
+
+	b, err := js.PrettySignatureAt("/metadata/signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+
+	var document struct {
+		testManifest
+		Metadata struct {
+			Created    string            `json:"created"`
+			Signatures []json.RawMessage `json:"signatures"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(b, &document); err != nil {
+		t.Fatalf("Invalid pretty signature %s: %s", b, err)
+	}
+	if document.Metadata.Created != manifest.Metadata.Created || len(document.Metadata.Signatures) != 1 {
+		t.Fatalf("Unexpected document: %s", b)
+	}
+
+	parsed, err := ParsePrettySignatureAt(b, "/metadata/signatures")
+	if err != nil {
+		t.Fatalf("Error parsing signature: %s", err)
+	}
+	keys, err := parsed.Verify()
+	if err != nil {
+		t.Fatalf("Error verifying signature: %s", err)
+	}
+	if len(keys) != 1 || keys[0].KeyID() != key.KeyID() {
+		t.Fatalf("Unexpected verified keys: %v", keys)
+	}
+
+	rebuilt, err := parsed.PrettySignatureAt("/metadata/signatures")
+	if err != nil {
+		t.Fatalf("Error formatting parsed signature: %s", err)
+	}
+	if !bytes.Equal(rebuilt, b) {
+		t.Fatalf("Rebuilt signature differs\n\tExpected: %s\n\tActual: %s", b, rebuilt)
+	}
+
+	if _, err := ParsePrettySignature(b, "signatures"); err != ErrMissingSignatureKey {
+		t.Fatalf("Expected missing signature key error, got %v", err)
+	}
+}
+
+func TestTamperAfterSignatureLocation(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	js, err := NewJSONSignature([]byte(`{
+   "metadata": {
+      "created": "2014-08-26T00:00:00Z"
+   },
+   "layers": ["good"]
+}`))
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SetSignatureLocation("/metadata/signatures"); err != nil {
+		t.Fatalf("Error setting signature location: %s", err)
+	}
+	if err := js.Sign(key); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	b, err := js.PrettySignatureAt("/metadata/signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	if _, err := ParsePrettySignatureAt(b, "/metadata/signatures"); err != nil {
+		t.Fatalf("Error parsing signature: %s", err)
+	}
+
+	signaturesEnd := bytes.Index(b, []byte("]\n   }"))
+	if signaturesEnd < 0 {
+		t.Fatalf("Unexpected formatted signature: %s", b)
+	}
+	for _, tampered := range [][]byte{
+		bytes.Replace(b, []byte(`"good"`), []byte(`"evil"`), 1),
+		bytes.Replace(b, []byte(`"layers"`), []byte(`"extra": 1, "layers"`), 1),
+		append(append(append([]byte{}, b[:signaturesEnd+1]...), `, "extra": 1`...), b[signaturesEnd+1:]...),
+	} {
+		if bytes.Equal(tampered, b) {
+			t.Fatalf("Tampering did not change document: %s", b)
+		}
+		if _, err := ParsePrettySignatureAt(tampered, "/metadata/signatures"); err == nil {
+			t.Fatalf("Expected error parsing tampered document: %s", tampered)
+		}
+	}
+}
+
+func TestSignArrayAtLocation(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	content := []byte(`[
+	{"name": "first"},
+	{"name": "second"}
+]`)
+	for _, canonical := range []bool{false, true} {
+		var js *JSONSignature
+		if canonical {
+			js, err = NewJSONSignatureCanonical(content)
+		} else {
+			var manifests []map[string]interface{}
+			if err := json.Unmarshal(content, &manifests); err != nil {
+				t.Fatalf("Error unmarshalling content: %s", err)
+			}
+			js, err = NewJSONSignatureFromMap(manifests)
+		}
+		if err != nil {
+			t.Fatalf("Error creating JSON signature: %s", err)
+		}
+		if err := js.SetSignatureLocation("/1/signatures"); err != nil {
+			t.Fatalf("Error setting signature location: %s", err)
+		}
+		if err := js.Sign(key); err != nil {
+			t.Fatalf("Error signing content: %s", err)
+		}
+
+		b, err := js.PrettySignatureAt("/1/signatures")
+		if err != nil {
+			t.Fatalf("Error formatting signature: %s", err)
+		}
+		var manifests []map[string]interface{}
+		if err := json.Unmarshal(b, &manifests); err != nil {
+			t.Fatalf("Invalid pretty signature %s: %s", b, err)
+		}
+		if _, ok := manifests[1]["signatures"]; !ok || len(manifests) != 2 {
+			t.Fatalf("Unexpected document: %s", b)
+		}
+
+		parsed, err := ParsePrettySignatureAt(b, "/1/signatures")
+		if err != nil {
+			t.Fatalf("Error parsing signature: %s", err)
+		}
+		if _, err := parsed.Verify(); err != nil {
+			t.Fatalf("Error verifying signature: %s", err)
+		}
+	}
+
+	js, err := NewJSONSignatureFromMap([]string{"a"})
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.Sign(key); err == nil {
+		t.Fatalf("Expected error signing an array without a signature location")
+	}
+	if err := js.SetSignatureLocation("/0/signatures"); err == nil {
+		t.Fatalf("Expected error setting signature location in a string")
+	}
+	if _, err := NewJSONSignatureFromMap("string"); err == nil {
+		t.Fatalf("Expected error creating JSON signature from a string")
+	}
+}
@@ -0,0 +1,308 @@
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/libtrust"
+)
+
+// Key management algorithms.
+const (
+	// ECDHES uses the key agreed with ECDH-ES directly as the content
+	// encryption key. It only supports a single recipient.
+	ECDHES = "ECDH-ES"
+	// ECDHESA128KW wraps the content encryption key with a 128 bit key
+	// agreed with ECDH-ES.
+	ECDHESA128KW = "ECDH-ES+A128KW"
+	// ECDHESA256KW wraps the content encryption key with a 256 bit key
+	// agreed with ECDH-ES.
+	ECDHESA256KW = "ECDH-ES+A256KW"
+	// RSAOAEP encrypts the content encryption key with RSAES OAEP using
+	// SHA-1 and MGF1 with SHA-1.
+	RSAOAEP = "RSA-OAEP"
+)
+
+// Content encryption algorithms.
+const (
+	// A128GCM encrypts content with AES GCM using a 128 bit key.
+	A128GCM = "A128GCM"
+	// A256GCM encrypts content with AES GCM using a 256 bit key.
+	A256GCM = "A256GCM"
+)
+
+const gcmTagSize = 16
+
+// contentKeyLength returns the length in bytes of the key used by the
+// content encryption algorithm.
+func contentKeyLength(enc string) (int, error) {
+	switch enc {
+	case A128GCM:
+		return 16, nil
+	case A256GCM:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("content encryption algorithm %q not supported", enc)
+	}
+}
+
+// defaultAlgorithm returns the key management algorithm used for the key
+// when a recipient does not specify one.
+func defaultAlgorithm(key libtrust.PublicKey) (string, error) {
+	switch key.CryptoPublicKey().(type) {
+	case *ecdsa.PublicKey:
+		return ECDHESA128KW, nil
+	case *rsa.PublicKey:
+		return RSAOAEP, nil
+	default:
+		return "", fmt.Errorf("public key type %T is not supported", key.CryptoPublicKey())
+	}
+}
+
+// wrapKeyLength returns the length in bytes of the key agreed with ECDH-ES
+// to wrap the content encryption key.
+func wrapKeyLength(alg string) int {
+	switch alg {
+	case ECDHESA128KW:
+		return 16
+	case ECDHESA256KW:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// encryptKey encrypts the content encryption key for the recipient key
+// and sets the recipient parameters in the header. For direct key agreement
+// the agreed key is returned in place of the given content encryption key
+// and the encrypted key is empty.
+func encryptKey(key libtrust.PublicKey, header *Header, cek []byte) ([]byte, []byte, error) {
+	switch header.Algorithm {
+	case ECDHES, ECDHESA128KW, ECDHESA256KW:
+		pub, ok := key.CryptoPublicKey().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("algorithm %q requires an EC key", header.Algorithm)
+		}
+		ephemeral, err := ecdsa.GenerateKey(pub.Curve, rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error generating ephemeral key: %s", err)
+		}
+		epk, err := libtrust.FromCryptoPublicKey(&ephemeral.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.EphemeralKey, err = epk.MarshalJSON()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if header.Algorithm == ECDHES {
+			agreed, err := agreeKey(ephemeral, pub, header.Encryption, len(cek), header)
+			if err != nil {
+				return nil, nil, err
+			}
+			return agreed, nil, nil
+		}
+
+		kek, err := agreeKey(ephemeral, pub, header.Algorithm, wrapKeyLength(header.Algorithm), header)
+		if err != nil {
+			return nil, nil, err
+		}
+		encryptedKey, err := keyWrap(kek, cek)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cek, encryptedKey, nil
+	case RSAOAEP:
+		pub, ok := key.CryptoPublicKey().(*rsa.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("algorithm %q requires an RSA key", header.Algorithm)
+		}
+		encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, cek, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error encrypting key: %s", err)
+		}
+		return cek, encryptedKey, nil
+	default:
+		return nil, nil, fmt.Errorf("key management algorithm %q not supported", header.Algorithm)
+	}
+}
+
+// decryptKey recovers the content encryption key for the given recipient
+// header using the private key.
+func decryptKey(key libtrust.PrivateKey, header *Header, encryptedKey []byte) ([]byte, error) {
+	cekLen, err := contentKeyLength(header.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	switch header.Algorithm {
+	case ECDHES, ECDHESA128KW, ECDHESA256KW:
+		priv, ok := key.CryptoPrivateKey().(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %q requires an EC key", header.Algorithm)
+		}
+		if len(header.EphemeralKey) == 0 {
+			return nil, errors.New("missing ephemeral public key")
+		}
+		epk, err := libtrust.UnmarshalPublicKeyJWK(header.EphemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ephemeral public key: %s", err)
+		}
+		pub, ok := epk.CryptoPublicKey().(*ecdsa.PublicKey)
+		if !ok || pub.Curve != priv.Curve {
+			return nil, errors.New("ephemeral public key does not match private key curve")
+		}
+
+		if header.Algorithm == ECDHES {
+			if len(encryptedKey) != 0 {
+				return nil, errors.New("unexpected encrypted key for direct key agreement")
+			}
+			return agreeKey(priv, pub, header.Encryption, cekLen, header)
+		}
+
+		kek, err := agreeKey(priv, pub, header.Algorithm, wrapKeyLength(header.Algorithm), header)
+		if err != nil {
+			return nil, err
+		}
+		cek, err := keyUnwrap(kek, encryptedKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(cek) != cekLen {
+			return nil, errors.New("invalid content encryption key length")
+		}
+		return cek, nil
+	case RSAOAEP:
+		priv, ok := key.CryptoPrivateKey().(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %q requires an RSA key", header.Algorithm)
+		}
+		cek, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, priv, encryptedKey, nil)
+		if err != nil {
+			return nil, errors.New("error decrypting key")
+		}
+		if len(cek) != cekLen {
+			return nil, errors.New("invalid content encryption key length")
+		}
+		return cek, nil
+	default:
+		return nil, fmt.Errorf("key management algorithm %q not supported", header.Algorithm)
+	}
+}
+
+// agreeKey derives a key of the given length from the ECDH shared secret
+// of priv and pub using the Concat KDF as described in section 4.6.2 of
+// RFC 7518.
+func agreeKey(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey, algID string, keyLen int, header *Header) ([]byte, error) {
+	privECDH, err := priv.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	pubECDH, err := pub.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key for key agreement: %s", err)
+	}
+	z, err := privECDH.ECDH(pubECDH)
+	if err != nil {
+		return nil, err
+	}
+
+	apu, err := decodeSegment(header.PartyUInfo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apu: %s", err)
+	}
+	apv, err := decodeSegment(header.PartyVInfo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apv: %s", err)
+	}
+
+	return concatKDF(z, []byte(algID), apu, apv, keyLen), nil
+}
+
+// concatKDF implements the single step key derivation function from
+// section 5.8.1 of NIST SP 800-56A with SHA-256.
+func concatKDF(z, algID, apu, apv []byte, keyLen int) []byte {
+	var otherInfo []byte
+	for _, field := range [][]byte{algID, apu, apv} {
+		otherInfo = appendUint32(otherInfo, uint32(len(field)))
+		otherInfo = append(otherInfo, field...)
+	}
+	otherInfo = appendUint32(otherInfo, uint32(keyLen*8))
+
+	var derived []byte
+	for counter := uint32(1); len(derived) < keyLen; counter++ {
+		hasher := sha256.New()
+		hasher.Write(appendUint32(nil, counter))
+		hasher.Write(z)
+		hasher.Write(otherInfo)
+		derived = hasher.Sum(derived)
+	}
+
+	return derived[:keyLen]
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// encryptContent encrypts the plaintext with AES GCM, returning the
+// initialization vector, ciphertext and authentication tag.
+func encryptContent(cek, plaintext, aad []byte) ([]byte, []byte, []byte, error) {
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, nil, nil, err
+	}
+
+	sealed := gcm.Seal(nil, iv, plaintext, aad)
+	split := len(sealed) - gcmTagSize
+
+	return iv, sealed[:split], sealed[split:], nil
+}
+
+// decryptContent authenticates and decrypts content encrypted with
+// encryptContent.
+func decryptContent(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != gcm.NonceSize() || len(tag) != gcmTagSize {
+		return nil, errors.New("invalid initialization vector or authentication tag length")
+	}
+
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(sealed, ciphertext...)
+	sealed = append(sealed, tag...)
+
+	plaintext, err := gcm.Open(nil, iv, sealed, aad)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+
+	return plaintext, nil
+}
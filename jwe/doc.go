@@ -0,0 +1,7 @@
+/*
+Package jwe encrypts and decrypts content for libtrust keys using JSON Web
+Encryption. EC keys are supported with ECDH-ES key agreement, either directly
+or with AES key wrapping, and RSA keys with RSA-OAEP. Content is encrypted
+with AES GCM and may be serialized in either the compact or the JSON form.
+*/
+package jwe
@@ -0,0 +1,392 @@
+package jwe
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/libtrust"
+)
+
+var (
+	// ErrNoMatchingRecipient is returned when none of the recipients of an
+	// encrypted object can be decrypted with the given key.
+	ErrNoMatchingRecipient = errors.New("no recipient matches decryption key")
+
+	// ErrDecryption is returned when the content fails to authenticate.
+	ErrDecryption = errors.New("unable to decrypt content")
+
+	// ErrMalformedObject is returned when parsing an invalid serialization.
+	ErrMalformedObject = errors.New("malformed encrypted object")
+)
+
+// Header holds the JOSE header parameters used by this package.
+type Header struct {
+	Algorithm    string          `json:"alg,omitempty"`
+	Encryption   string          `json:"enc,omitempty"`
+	KeyID        string          `json:"kid,omitempty"`
+	EphemeralKey json.RawMessage `json:"epk,omitempty"`
+	PartyUInfo   string          `json:"apu,omitempty"`
+	PartyVInfo   string          `json:"apv,omitempty"`
+}
+
+// merge returns the union of the headers, failing if a parameter is set in
+// more than one of them.
+func merge(headers ...*Header) (*Header, error) {
+	merged := &Header{}
+	for _, h := range headers {
+		if h == nil {
+			continue
+		}
+		for _, field := range []struct {
+			name     string
+			dst, src *string
+		}{
+			{"alg", &merged.Algorithm, &h.Algorithm},
+			{"enc", &merged.Encryption, &h.Encryption},
+			{"kid", &merged.KeyID, &h.KeyID},
+			{"apu", &merged.PartyUInfo, &h.PartyUInfo},
+			{"apv", &merged.PartyVInfo, &h.PartyVInfo},
+		} {
+			if *field.src == "" {
+				continue
+			}
+			if *field.dst != "" {
+				return nil, fmt.Errorf("duplicate header parameter %q", field.name)
+			}
+			*field.dst = *field.src
+		}
+		if len(h.EphemeralKey) > 0 {
+			if len(merged.EphemeralKey) > 0 {
+				return nil, errors.New(`duplicate header parameter "epk"`)
+			}
+			merged.EphemeralKey = h.EphemeralKey
+		}
+	}
+	return merged, nil
+}
+
+// Recipient identifies a key the content is encrypted for.
+type Recipient struct {
+	Key libtrust.PublicKey
+	// Algorithm is the key management algorithm. If empty, ECDH-ES+A128KW
+	// is used for EC keys and RSA-OAEP for RSA keys.
+	Algorithm string
+}
+
+type recipient struct {
+	header       *Header
+	encryptedKey []byte
+}
+
+// Object is a JSON Web Encryption object, encrypted for one or more
+// recipients.
+type Object struct {
+	protected   string
+	header      *Header
+	unprotected *Header
+	recipients  []recipient
+	aad         []byte
+	iv          []byte
+	ciphertext  []byte
+	tag         []byte
+}
+
+// Encrypt encrypts the plaintext with the given content encryption
+// algorithm for each of the recipients. When there is a single recipient
+// all header parameters are integrity protected, allowing the object to be
+// serialized in the compact form.
+func Encrypt(plaintext []byte, enc string, recipients ...Recipient) (*Object, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("missing recipients")
+	}
+	cekLen, err := contentKeyLength(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	cek := make([]byte, cekLen)
+	if _, err := io.ReadFull(rand.Reader, cek); err != nil {
+		return nil, err
+	}
+
+	obj := &Object{
+		header:     &Header{Encryption: enc},
+		recipients: make([]recipient, len(recipients)),
+	}
+	for i, r := range recipients {
+		alg := r.Algorithm
+		if alg == "" {
+			alg, err = defaultAlgorithm(r.Key)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if alg == ECDHES && len(recipients) > 1 {
+			return nil, fmt.Errorf("algorithm %q only supports a single recipient", ECDHES)
+		}
+
+		header := &Header{
+			Algorithm: alg,
+			KeyID:     r.Key.KeyID(),
+		}
+		if len(recipients) == 1 {
+			header = obj.header
+			header.Algorithm = alg
+			header.KeyID = r.Key.KeyID()
+		}
+
+		// The KDF reads the content encryption algorithm from the merged
+		// header, which for several recipients is split in two.
+		keyHeader := *header
+		keyHeader.Encryption = enc
+		cek, obj.recipients[i].encryptedKey, err = encryptKey(r.Key, &keyHeader, cek)
+		if err != nil {
+			return nil, err
+		}
+		header.EphemeralKey = keyHeader.EphemeralKey
+
+		if len(recipients) > 1 {
+			obj.recipients[i].header = header
+		}
+	}
+
+	protected, err := json.Marshal(obj.header)
+	if err != nil {
+		return nil, err
+	}
+	obj.protected = encodeSegment(protected)
+
+	obj.iv, obj.ciphertext, obj.tag, err = encryptContent(cek, plaintext, obj.additionalData())
+	if err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// additionalData returns the additional authenticated data for the content
+// encryption, as defined in step 14 of section 5.1 of RFC 7516.
+func (obj *Object) additionalData() []byte {
+	if len(obj.aad) == 0 {
+		return []byte(obj.protected)
+	}
+	return []byte(obj.protected + "." + encodeSegment(obj.aad))
+}
+
+// Recipients returns the merged header of each recipient of the object.
+func (obj *Object) Recipients() ([]*Header, error) {
+	headers := make([]*Header, len(obj.recipients))
+	for i, r := range obj.recipients {
+		header, err := merge(obj.header, obj.unprotected, r.header)
+		if err != nil {
+			return nil, err
+		}
+		headers[i] = header
+	}
+	return headers, nil
+}
+
+// Decrypt decrypts the content using the given private key. Recipients
+// which name a different key ID are skipped.
+func (obj *Object) Decrypt(key libtrust.PrivateKey) ([]byte, error) {
+	headers, err := obj.Recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	lastErr := ErrNoMatchingRecipient
+	for i, header := range headers {
+		if header.KeyID != "" && header.KeyID != key.KeyID() {
+			continue
+		}
+		cek, err := decryptKey(key, header, obj.recipients[i].encryptedKey)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return decryptContent(cek, obj.iv, obj.ciphertext, obj.tag, obj.additionalData())
+	}
+
+	return nil, lastErr
+}
+
+// CompactSerialize returns the compact serialization of the object. Only
+// objects with a single recipient and no unprotected header parameters or
+// additional authenticated data can be serialized in this form.
+func (obj *Object) CompactSerialize() (string, error) {
+	if len(obj.recipients) != 1 || obj.recipients[0].header != nil || obj.unprotected != nil || len(obj.aad) != 0 {
+		return "", errors.New("object cannot be serialized in compact form")
+	}
+
+	return strings.Join([]string{
+		obj.protected,
+		encodeSegment(obj.recipients[0].encryptedKey),
+		encodeSegment(obj.iv),
+		encodeSegment(obj.ciphertext),
+		encodeSegment(obj.tag),
+	}, "."), nil
+}
+
+type jsonRecipient struct {
+	Header       *Header `json:"header,omitempty"`
+	EncryptedKey string  `json:"encrypted_key,omitempty"`
+}
+
+type jsonObject struct {
+	Protected   string          `json:"protected,omitempty"`
+	Unprotected *Header         `json:"unprotected,omitempty"`
+	Recipients  []jsonRecipient `json:"recipients,omitempty"`
+	AAD         string          `json:"aad,omitempty"`
+	IV          string          `json:"iv"`
+	Ciphertext  string          `json:"ciphertext"`
+	Tag         string          `json:"tag"`
+
+	// Members of the flattened serialization.
+	Header       *Header `json:"header,omitempty"`
+	EncryptedKey string  `json:"encrypted_key,omitempty"`
+}
+
+// JSONSerialize returns the general JSON serialization of the object.
+func (obj *Object) JSONSerialize() ([]byte, error) {
+	jsonObj := jsonObject{
+		Protected:   obj.protected,
+		Unprotected: obj.unprotected,
+		Recipients:  make([]jsonRecipient, len(obj.recipients)),
+		IV:          encodeSegment(obj.iv),
+		Ciphertext:  encodeSegment(obj.ciphertext),
+		Tag:         encodeSegment(obj.tag),
+	}
+	if len(obj.aad) > 0 {
+		jsonObj.AAD = encodeSegment(obj.aad)
+	}
+	for i, r := range obj.recipients {
+		jsonObj.Recipients[i] = jsonRecipient{
+			Header:       r.header,
+			EncryptedKey: encodeSegment(r.encryptedKey),
+		}
+	}
+
+	return json.MarshalIndent(jsonObj, "", "   ")
+}
+
+// Parse parses an encrypted object in either the compact or the JSON
+// serialization.
+func Parse(data []byte) (*Object, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		return ParseJSON(data)
+	}
+	return ParseCompact(string(data))
+}
+
+// ParseCompact parses the compact serialization of an encrypted object.
+func ParseCompact(s string) (*Object, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 5 {
+		return nil, ErrMalformedObject
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts[1:] {
+		var err error
+		decoded[i+1], err = decodeSegment(part)
+		if err != nil {
+			return nil, ErrMalformedObject
+		}
+	}
+
+	obj := &Object{
+		protected:  parts[0],
+		recipients: []recipient{{encryptedKey: decoded[1]}},
+		iv:         decoded[2],
+		ciphertext: decoded[3],
+		tag:        decoded[4],
+	}
+	if err := obj.decodeProtected(); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// ParseJSON parses the general or flattened JSON serialization of an
+// encrypted object.
+func ParseJSON(data []byte) (*Object, error) {
+	var jsonObj jsonObject
+	if err := json.Unmarshal(data, &jsonObj); err != nil {
+		return nil, err
+	}
+
+	if jsonObj.Header != nil || jsonObj.EncryptedKey != "" {
+		if len(jsonObj.Recipients) > 0 {
+			return nil, ErrMalformedObject
+		}
+		jsonObj.Recipients = []jsonRecipient{{
+			Header:       jsonObj.Header,
+			EncryptedKey: jsonObj.EncryptedKey,
+		}}
+	}
+	if len(jsonObj.Recipients) == 0 {
+		return nil, ErrMalformedObject
+	}
+
+	obj := &Object{
+		protected:   jsonObj.Protected,
+		unprotected: jsonObj.Unprotected,
+		recipients:  make([]recipient, len(jsonObj.Recipients)),
+	}
+	var err error
+	for i, r := range jsonObj.Recipients {
+		obj.recipients[i].header = r.Header
+		if obj.recipients[i].encryptedKey, err = decodeSegment(r.EncryptedKey); err != nil {
+			return nil, ErrMalformedObject
+		}
+	}
+	for _, field := range []struct {
+		dst *[]byte
+		src string
+	}{
+		{&obj.aad, jsonObj.AAD},
+		{&obj.iv, jsonObj.IV},
+		{&obj.ciphertext, jsonObj.Ciphertext},
+		{&obj.tag, jsonObj.Tag},
+	} {
+		if *field.dst, err = decodeSegment(field.src); err != nil {
+			return nil, ErrMalformedObject
+		}
+	}
+	if err := obj.decodeProtected(); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+func (obj *Object) decodeProtected() error {
+	obj.header = &Header{}
+	if obj.protected == "" {
+		return nil
+	}
+	b, err := decodeSegment(obj.protected)
+	if err != nil {
+		return ErrMalformedObject
+	}
+	if err := json.Unmarshal(b, obj.header); err != nil {
+		return fmt.Errorf("unable to decode protected header: %s", err)
+	}
+	return nil
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,166 @@
+package jwe
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/docker/libtrust"
+)
+
+func generateKeys(t *testing.T) (ecKeys []libtrust.PrivateKey, rsaKey libtrust.PrivateKey) {
+	for _, generate := range []func() (libtrust.PrivateKey, error){
+		libtrust.GenerateECP256PrivateKey,
+		libtrust.GenerateECP384PrivateKey,
+		libtrust.GenerateECP521PrivateKey,
+	} {
+		key, err := generate()
+		if err != nil {
+			t.Fatalf("Error generating EC key: %s", err)
+		}
+		ecKeys = append(ecKeys, key)
+	}
+	rsaKey, err := libtrust.GenerateRSA2048PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %s", err)
+	}
+	return ecKeys, rsaKey
+}
+
+func TestEncryptCompact(t *testing.T) {
+	ecKeys, rsaKey := generateKeys(t)
+	plaintext := []byte("registry credentials")
+
+	type testCase struct {
+		key libtrust.PrivateKey
+		alg string
+	}
+	var cases []testCase
+	for _, key := range ecKeys {
+		for _, alg := range []string{"", ECDHES, ECDHESA128KW, ECDHESA256KW} {
+			cases = append(cases, testCase{key, alg})
+		}
+	}
+	cases = append(cases, testCase{rsaKey, ""}, testCase{rsaKey, RSAOAEP})
+
+	for _, c := range cases {
+		for _, enc := range []string{A128GCM, A256GCM} {
+			obj, err := Encrypt(plaintext, enc, Recipient{Key: c.key.PublicKey(), Algorithm: c.alg})
+			if err != nil {
+				t.Fatalf("Error encrypting with %s/%q/%s: %s", c.key, c.alg, enc, err)
+			}
+			compact, err := obj.CompactSerialize()
+			if err != nil {
+				t.Fatalf("Error serializing: %s", err)
+			}
+
+			parsed, err := Parse([]byte(compact))
+			if err != nil {
+				t.Fatalf("Error parsing: %s", err)
+			}
+			decrypted, err := parsed.Decrypt(c.key)
+			if err != nil {
+				t.Fatalf("Error decrypting with %s/%q/%s: %s", c.key, c.alg, enc, err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatalf("Unexpected plaintext: %q", decrypted)
+			}
+		}
+	}
+}
+
+func TestEncryptJSONMultipleRecipients(t *testing.T) {
+	ecKeys, rsaKey := generateKeys(t)
+	plaintext := []byte(`{"password":"hunter2"}`)
+
+	obj, err := Encrypt(plaintext, A256GCM,
+		Recipient{Key: ecKeys[0].PublicKey()},
+		Recipient{Key: ecKeys[1].PublicKey(), Algorithm: ECDHESA256KW},
+		Recipient{Key: rsaKey.PublicKey()},
+	)
+	if err != nil {
+		t.Fatalf("Error encrypting: %s", err)
+	}
+	if _, err := obj.CompactSerialize(); err == nil {
+		t.Fatalf("Expected error serializing multiple recipients in compact form")
+	}
+
+	serialized, err := obj.JSONSerialize()
+	if err != nil {
+		t.Fatalf("Error serializing: %s", err)
+	}
+	parsed, err := Parse(serialized)
+	if err != nil {
+		t.Fatalf("Error parsing: %s", err)
+	}
+	headers, err := parsed.Recipients()
+	if err != nil {
+		t.Fatalf("Error reading recipients: %s", err)
+	}
+	if len(headers) != 3 || headers[2].Algorithm != RSAOAEP || headers[2].Encryption != A256GCM {
+		t.Fatalf("Unexpected recipients: %#v", headers)
+	}
+
+	for _, key := range []libtrust.PrivateKey{ecKeys[0], ecKeys[1], rsaKey} {
+		decrypted, err := parsed.Decrypt(key)
+		if err != nil {
+			t.Fatalf("Error decrypting with %s: %s", key, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("Unexpected plaintext: %q", decrypted)
+		}
+	}
+
+	if _, err := parsed.Decrypt(ecKeys[2]); err != ErrNoMatchingRecipient {
+		t.Fatalf("Unexpected error decrypting with other key: %v", err)
+	}
+
+	if _, err := Encrypt(plaintext, A128GCM,
+		Recipient{Key: ecKeys[0].PublicKey(), Algorithm: ECDHES},
+		Recipient{Key: rsaKey.PublicKey()},
+	); err == nil {
+		t.Fatalf("Expected error using direct key agreement with several recipients")
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	ecKeys, _ := generateKeys(t)
+	key := ecKeys[0]
+
+	obj, err := Encrypt([]byte("secret"), A128GCM, Recipient{Key: key.PublicKey()})
+	if err != nil {
+		t.Fatalf("Error encrypting: %s", err)
+	}
+	compact, err := obj.CompactSerialize()
+	if err != nil {
+		t.Fatalf("Error serializing: %s", err)
+	}
+	parts := strings.Split(compact, ".")
+
+	ciphertext, _ := decodeSegment(parts[3])
+	ciphertext[0] ^= 0x01
+	parts[3] = encodeSegment(ciphertext)
+	parsed, err := ParseCompact(strings.Join(parts, "."))
+	if err != nil {
+		t.Fatalf("Error parsing: %s", err)
+	}
+	if _, err := parsed.Decrypt(key); err != ErrDecryption {
+		t.Fatalf("Unexpected error decrypting modified ciphertext: %v", err)
+	}
+
+	if _, err := ParseCompact("a.b.c"); err != ErrMalformedObject {
+		t.Fatalf("Unexpected error parsing malformed object: %v", err)
+	}
+}
+
+// Test vector from appendix C of RFC 7518.
+func TestConcatKDF(t *testing.T) {
+	z := []byte{158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132,
+		38, 156, 251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121,
+		140, 254, 144, 196}
+
+	derived := concatKDF(z, []byte("A128GCM"), []byte("Alice"), []byte("Bob"), 16)
+	if encodeSegment(derived) != "VqqN6vgjbSBcIijNcacQGg" {
+		t.Fatalf("Unexpected derived key: %s", encodeSegment(derived))
+	}
+}
@@ -0,0 +1,94 @@
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// defaultIV is the initial value defined in section 2.2.3.1 of RFC 3394.
+var defaultIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// keyWrap wraps the content encryption key with the key encryption key
+// using the AES Key Wrap algorithm from RFC 3394.
+func keyWrap(kek, cek []byte) ([]byte, error) {
+	if len(cek)%8 != 0 || len(cek) < 16 {
+		return nil, errors.New("key to wrap must be a multiple of 8 bytes and at least 16 bytes")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(cek) / 8
+	r := make([][]byte, n)
+	for i := range r {
+		r[i] = make([]byte, 8)
+		copy(r[i], cek[i*8:])
+	}
+
+	buf := make([]byte, 16)
+	copy(buf, defaultIV)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(buf[8:], r[i])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i + 1)
+			a := binary.BigEndian.Uint64(buf[:8]) ^ t
+			binary.BigEndian.PutUint64(buf[:8], a)
+			copy(r[i], buf[8:])
+		}
+	}
+
+	wrapped := make([]byte, 0, (n+1)*8)
+	wrapped = append(wrapped, buf[:8]...)
+	for i := range r {
+		wrapped = append(wrapped, r[i]...)
+	}
+
+	return wrapped, nil
+}
+
+// keyUnwrap unwraps a key wrapped with keyWrap, checking its integrity.
+func keyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, errors.New("wrapped key must be a multiple of 8 bytes and at least 24 bytes")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	r := make([][]byte, n)
+	for i := range r {
+		r[i] = make([]byte, 8)
+		copy(r[i], wrapped[(i+1)*8:])
+	}
+
+	buf := make([]byte, 16)
+	copy(buf, wrapped[:8])
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			a := binary.BigEndian.Uint64(buf[:8]) ^ t
+			binary.BigEndian.PutUint64(buf[:8], a)
+			copy(buf[8:], r[i])
+			block.Decrypt(buf, buf)
+			copy(r[i], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(buf[:8], defaultIV) != 1 {
+		return nil, errors.New("key unwrap integrity check failed")
+	}
+
+	cek := make([]byte, 0, n*8)
+	for i := range r {
+		cek = append(cek, r[i]...)
+	}
+
+	return cek, nil
+}
@@ -0,0 +1,63 @@
+package jwe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Error decoding hex: %s", err)
+	}
+	return b
+}
+
+// Test vectors from section 4 of RFC 3394.
+func TestKeyWrapVectors(t *testing.T) {
+	for _, v := range []struct {
+		kek, key, wrapped string
+	}{
+		{
+			"000102030405060708090A0B0C0D0E0F",
+			"00112233445566778899AABBCCDDEEFF",
+			"1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5",
+		},
+		{
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF",
+			"64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7",
+		},
+		{
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F",
+			"28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21",
+		},
+	} {
+		kek := mustDecodeHex(t, v.kek)
+		key := mustDecodeHex(t, v.key)
+		expected := mustDecodeHex(t, v.wrapped)
+
+		wrapped, err := keyWrap(kek, key)
+		if err != nil {
+			t.Fatalf("Error wrapping key: %s", err)
+		}
+		if !bytes.Equal(wrapped, expected) {
+			t.Fatalf("Unexpected wrapped key %X, expected %X", wrapped, expected)
+		}
+
+		unwrapped, err := keyUnwrap(kek, wrapped)
+		if err != nil {
+			t.Fatalf("Error unwrapping key: %s", err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Fatalf("Unexpected unwrapped key %X, expected %X", unwrapped, key)
+		}
+
+		wrapped[3] ^= 0x01
+		if _, err := keyUnwrap(kek, wrapped); err == nil {
+			t.Fatalf("Expected integrity error unwrapping modified key")
+		}
+	}
+}
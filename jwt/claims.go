@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Audience is the list of recipients a token is intended for. It is
+// serialized as a single string when it holds exactly one value.
+type Audience []string
+
+// MarshalJSON serializes a single audience as a string and any other
+// number of audiences as an array.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts either a single string or an array of strings. A
+// null audience is the same as no audience.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*a = nil
+		return nil
+	}
+
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("invalid audience: %s", err)
+	}
+	*a = Audience(multiple)
+
+	return nil
+}
+
+// Contains returns whether the given audience is one of the token's
+// intended recipients.
+func (a Audience) Contains(audience string) bool {
+	for _, aud := range a {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// registeredClaims holds the claim names registered in section 4.1 of the
+// JWT specification, which may not be set as custom claims.
+var registeredClaims = map[string]bool{
+	"iss": true,
+	"sub": true,
+	"aud": true,
+	"exp": true,
+	"nbf": true,
+	"iat": true,
+	"jti": true,
+}
+
+// ClaimSet is the set of claims carried by a token. Times are expressed as
+// seconds since the Unix epoch, with zero meaning the claim is not set.
+type ClaimSet struct {
+	Issuer     string   `json:"iss,omitempty"`
+	Subject    string   `json:"sub,omitempty"`
+	Audience   Audience `json:"aud,omitempty"`
+	Expiration int64    `json:"exp,omitempty"`
+	NotBefore  int64    `json:"nbf,omitempty"`
+	IssuedAt   int64    `json:"iat,omitempty"`
+	JWTID      string   `json:"jti,omitempty"`
+
+	// Custom holds any claims other than the registered ones.
+	Custom map[string]interface{} `json:"-"`
+}
+
+// registered is used to marshal the registered claims of a ClaimSet
+// without recursing into its own MarshalJSON method.
+type registered ClaimSet
+
+// MarshalJSON serializes the registered claims along with the custom
+// claims as a single JSON object.
+func (c *ClaimSet) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal((*registered)(c))
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Custom) == 0 {
+		return b, nil
+	}
+
+	claims := make(map[string]interface{}, len(c.Custom)+len(registeredClaims))
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, err
+	}
+	for name, value := range c.Custom {
+		if registeredClaims[name] {
+			return nil, fmt.Errorf("custom claim %q conflicts with a registered claim", name)
+		}
+		claims[name] = value
+	}
+
+	return json.Marshal(claims)
+}
+
+// UnmarshalJSON parses the registered claims into their fields and any
+// other claims into Custom.
+func (c *ClaimSet) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*registered)(c)); err != nil {
+		return err
+	}
+
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	for name, raw := range claims {
+		if registeredClaims[name] {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if c.Custom == nil {
+			c.Custom = make(map[string]interface{})
+		}
+		c.Custom[name] = value
+	}
+
+	return nil
+}
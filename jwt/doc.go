@@ -0,0 +1,7 @@
+/*
+Package jwt issues and validates JSON Web Tokens signed with libtrust keys.
+Tokens use the JWS compact serialization with the signature algorithm
+determined by the signing key, and are validated against a set of trusted
+public keys looked up by the "kid" header.
+*/
+package jwt
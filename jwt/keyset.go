@@ -0,0 +1,31 @@
+package jwt
+
+import (
+	"github.com/docker/libtrust"
+)
+
+// KeySet looks up trusted public keys by key ID.
+type KeySet interface {
+	// Key returns the trusted key with the given ID or ErrUnknownKey.
+	Key(keyID string) (libtrust.PublicKey, error)
+}
+
+type keySet map[string]libtrust.PublicKey
+
+// NewKeySet returns a KeySet trusting the given keys, such as those
+// returned by libtrust.LoadKeySetFile.
+func NewKeySet(keys ...libtrust.PublicKey) KeySet {
+	set := make(keySet, len(keys))
+	for _, key := range keys {
+		set[key.KeyID()] = key
+	}
+	return set
+}
+
+func (s keySet) Key(keyID string) (libtrust.PublicKey, error) {
+	key, ok := s[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
@@ -0,0 +1,254 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/libtrust"
+)
+
+var (
+	// ErrMalformedToken is returned when a token is not a well formed JWS
+	// compact serialization.
+	ErrMalformedToken = errors.New("malformed token")
+
+	// ErrAlgorithmNone is returned when a token declares the "none"
+	// algorithm, which is never accepted.
+	ErrAlgorithmNone = errors.New(`token algorithm "none" is not allowed`)
+
+	// ErrAlgorithmMismatch is returned when the algorithm of a token is not
+	// one the signing key supports, such as an RSA algorithm with an EC key.
+	ErrAlgorithmMismatch = errors.New("token algorithm does not match signing key")
+
+	// ErrUnknownKey is returned when the key which signed a token is not in
+	// the trusted key set.
+	ErrUnknownKey = errors.New("token signed by unknown key")
+
+	// ErrInvalidSignature is returned when the signature of a token does
+	// not verify.
+	ErrInvalidSignature = errors.New("invalid token signature")
+
+	// ErrTokenExpired is returned when the expiration time of a token has
+	// passed.
+	ErrTokenExpired = errors.New("token is expired")
+
+	// ErrTokenNotYetValid is returned when the not before time of a token
+	// has not been reached.
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+
+	// ErrInvalidIssuer is returned when a token was not issued by the
+	// expected issuer.
+	ErrInvalidIssuer = errors.New("token issuer does not match")
+
+	// ErrInvalidAudience is returned when a token is not intended for the
+	// expected audience.
+	ErrInvalidAudience = errors.New("token audience does not match")
+)
+
+// Header is the JOSE header of a token.
+type Header struct {
+	Type      string `json:"typ"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Token is a parsed and verified JSON Web Token.
+type Token struct {
+	// Raw is the compact serialization of the token.
+	Raw    string
+	Header Header
+	Claims *ClaimSet
+	// Key is the trusted key which verified the token signature.
+	Key libtrust.PublicKey
+}
+
+// Sign serializes the claims into a token signed by the given key. The key
+// ID is set in the token header so that it can be looked up on validation.
+func Sign(claims *ClaimSet, key libtrust.PrivateKey) (string, error) {
+	alg, err := algorithmForKey(key.PublicKey())
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(Header{
+		Type:      "JWT",
+		Algorithm: alg,
+		KeyID:     key.KeyID(),
+	})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(header) + "." + encodeSegment(payload)
+	signature, signedAlg, err := key.Sign(strings.NewReader(signingInput), crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	if signedAlg != alg {
+		return "", fmt.Errorf("key signed with algorithm %q, expected %q", signedAlg, alg)
+	}
+
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// VerifyOptions are the checks applied when validating a token.
+type VerifyOptions struct {
+	// TrustedKeys is used to look up the key which signed the token.
+	TrustedKeys KeySet
+
+	// Issuer, when set, must match the "iss" claim.
+	Issuer string
+
+	// Audience, when set, must be one of the values of the "aud" claim.
+	Audience string
+
+	// Leeway allows for clock skew when checking the "exp" and "nbf"
+	// claims.
+	Leeway time.Duration
+
+	// CurrentTime is the time to validate the token at. If zero, the
+	// current time is used.
+	CurrentTime time.Time
+}
+
+// Parse verifies the signature of the given token using the trusted key
+// named in its header and validates its claims according to the options.
+func Parse(raw string, opts VerifyOptions) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	headerBytes, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	claimBytes, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	token := &Token{Raw: raw}
+	if err := json.Unmarshal(headerBytes, &token.Header); err != nil {
+		return nil, fmt.Errorf("unable to decode token header: %s", err)
+	}
+
+	if token.Header.Algorithm == "" || strings.EqualFold(token.Header.Algorithm, "none") {
+		return nil, ErrAlgorithmNone
+	}
+
+	if opts.TrustedKeys == nil {
+		return nil, ErrUnknownKey
+	}
+	key, err := opts.TrustedKeys.Key(token.Header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !algorithmAllowed(key, token.Header.Algorithm) {
+		return nil, ErrAlgorithmMismatch
+	}
+
+	signingInput := raw[:len(parts[0])+len(parts[1])+1]
+	if err := key.Verify(strings.NewReader(signingInput), token.Header.Algorithm, signature); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	token.Key = key
+
+	if err := json.Unmarshal(claimBytes, &token.Claims); err != nil {
+		return nil, fmt.Errorf("unable to decode token claims: %s", err)
+	}
+	if token.Claims == nil {
+		return nil, ErrMalformedToken
+	}
+
+	if err := token.Claims.validate(opts); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (c *ClaimSet) validate(opts VerifyOptions) error {
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	// A token must be used before its expiration time, not at it.
+	if c.Expiration != 0 && !now.Before(time.Unix(c.Expiration, 0).Add(opts.Leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Add(opts.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if opts.Issuer != "" && c.Issuer != opts.Issuer {
+		return ErrInvalidIssuer
+	}
+	if opts.Audience != "" && !c.Audience.Contains(opts.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// algorithmForKey returns the signature algorithm used by Sign for the key.
+// EC keys have a single algorithm determined by their curve while RSA keys
+// are always used with RS256.
+func algorithmForKey(key libtrust.PublicKey) (string, error) {
+	switch k := key.CryptoPublicKey().(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		}
+		return "", errors.New("unsupported elliptic curve")
+	case *rsa.PublicKey:
+		return "RS256", nil
+	default:
+		return "", fmt.Errorf("public key type %T is not supported", k)
+	}
+}
+
+// algorithmAllowed returns whether a token declaring the given algorithm
+// may be verified with the key. The algorithm family must match the key
+// type so that an RSA signature is never checked against an EC key or the
+// other way around.
+func algorithmAllowed(key libtrust.PublicKey, alg string) bool {
+	switch key.CryptoPublicKey().(type) {
+	case *ecdsa.PublicKey:
+		expected, err := algorithmForKey(key)
+		return err == nil && alg == expected
+	case *rsa.PublicKey:
+		return alg == "RS256" || alg == "RS384" || alg == "RS512"
+	default:
+		return false
+	}
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,182 @@
+package jwt
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust"
+)
+
+func generateKeys(t *testing.T) []libtrust.PrivateKey {
+	ecKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ec521Key, err := libtrust.GenerateECP521PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	rsaKey, err := libtrust.GenerateRSA2048PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %s", err)
+	}
+	return []libtrust.PrivateKey{ecKey, ec521Key, rsaKey}
+}
+
+func testClaims(now time.Time) *ClaimSet {
+	return &ClaimSet{
+		Issuer:     "auth.docker.io",
+		Subject:    "dmcgowan",
+		Audience:   Audience{"registry.docker.io"},
+		Expiration: now.Add(time.Hour).Unix(),
+		NotBefore:  now.Add(-time.Minute).Unix(),
+		IssuedAt:   now.Unix(),
+		JWTID:      "b6c4a1f2",
+		Custom: map[string]interface{}{
+			"access": []interface{}{"repository:library/ubuntu:pull"},
+		},
+	}
+}
+
+func TestSignAndParse(t *testing.T) {
+	now := time.Now()
+	for _, key := range generateKeys(t) {
+		raw, err := Sign(testClaims(now), key)
+		if err != nil {
+			t.Fatalf("Error signing token: %s", err)
+		}
+
+		token, err := Parse(raw, VerifyOptions{
+			TrustedKeys: NewKeySet(key.PublicKey()),
+			Issuer:      "auth.docker.io",
+			Audience:    "registry.docker.io",
+		})
+		if err != nil {
+			t.Fatalf("Error parsing token: %s", err)
+		}
+		if token.Key.KeyID() != key.KeyID() || token.Header.KeyID != key.KeyID() {
+			t.Fatalf("Unexpected key for token")
+		}
+		if token.Claims.Subject != "dmcgowan" || token.Claims.JWTID != "b6c4a1f2" {
+			t.Fatalf("Unexpected claims: %#v", token.Claims)
+		}
+		access, ok := token.Claims.Custom["access"].([]interface{})
+		if !ok || len(access) != 1 || access[0] != "repository:library/ubuntu:pull" {
+			t.Fatalf("Unexpected custom claims: %#v", token.Claims.Custom)
+		}
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	key := generateKeys(t)[0]
+	now := time.Now()
+	raw, err := Sign(testClaims(now), key)
+	if err != nil {
+		t.Fatalf("Error signing token: %s", err)
+	}
+	keys := NewKeySet(key.PublicKey())
+	expiration := time.Unix(testClaims(now).Expiration, 0)
+
+	for _, c := range []struct {
+		opts     VerifyOptions
+		expected error
+	}{
+		{VerifyOptions{TrustedKeys: keys, CurrentTime: now.Add(2 * time.Hour)}, ErrTokenExpired},
+		{VerifyOptions{TrustedKeys: keys, CurrentTime: now.Add(2 * time.Hour), Leeway: 2 * time.Hour}, nil},
+		{VerifyOptions{TrustedKeys: keys, CurrentTime: expiration}, ErrTokenExpired},
+		{VerifyOptions{TrustedKeys: keys, CurrentTime: expiration.Add(-time.Second)}, nil},
+		{VerifyOptions{TrustedKeys: keys, CurrentTime: now.Add(-time.Hour)}, ErrTokenNotYetValid},
+		{VerifyOptions{TrustedKeys: keys, CurrentTime: now.Add(-time.Hour), Leeway: time.Hour}, nil},
+		{VerifyOptions{TrustedKeys: keys, Audience: "index.docker.io"}, ErrInvalidAudience},
+		{VerifyOptions{TrustedKeys: keys, Issuer: "evil.example.com"}, ErrInvalidIssuer},
+		{VerifyOptions{TrustedKeys: NewKeySet()}, ErrUnknownKey},
+		{VerifyOptions{}, ErrUnknownKey},
+	} {
+		if _, err := Parse(raw, c.opts); err != c.expected {
+			t.Fatalf("Unexpected error %v, expected %v", err, c.expected)
+		}
+	}
+}
+
+func TestRejectUnsafeAlgorithms(t *testing.T) {
+	keys := generateKeys(t)
+	ecKey, rsaKey := keys[0], keys[2]
+	claims, err := json.Marshal(testClaims(time.Now()))
+	if err != nil {
+		t.Fatalf("Error marshalling claims: %s", err)
+	}
+
+	forge := func(alg string, key libtrust.PrivateKey) string {
+		header, err := json.Marshal(Header{Type: "JWT", Algorithm: alg, KeyID: key.KeyID()})
+		if err != nil {
+			t.Fatalf("Error marshalling header: %s", err)
+		}
+		return encodeSegment(header) + "." + encodeSegment(claims) + "."
+	}
+
+	opts := VerifyOptions{TrustedKeys: NewKeySet(ecKey.PublicKey(), rsaKey.PublicKey())}
+	for _, alg := range []string{"none", "None", ""} {
+		if _, err := Parse(forge(alg, ecKey), opts); err != ErrAlgorithmNone {
+			t.Fatalf("Unexpected error for algorithm %q: %v", alg, err)
+		}
+	}
+	if _, err := Parse(forge("RS256", ecKey), opts); err != ErrAlgorithmMismatch {
+		t.Fatalf("Unexpected error for RSA algorithm with EC key: %v", err)
+	}
+	if _, err := Parse(forge("ES256", rsaKey), opts); err != ErrAlgorithmMismatch {
+		t.Fatalf("Unexpected error for EC algorithm with RSA key: %v", err)
+	}
+	if _, err := Parse(forge("HS256", rsaKey), opts); err != ErrAlgorithmMismatch {
+		t.Fatalf("Unexpected error for HMAC algorithm: %v", err)
+	}
+
+	// A signature from one key must not verify under another key ID.
+	raw, err := Sign(testClaims(time.Now()), ecKey)
+	if err != nil {
+		t.Fatalf("Error signing token: %s", err)
+	}
+	other := generateKeys(t)[0]
+	if _, err := Parse(raw, VerifyOptions{TrustedKeys: keySet{ecKey.KeyID(): other.PublicKey()}}); err != ErrInvalidSignature {
+		t.Fatalf("Unexpected error for wrong key: %v", err)
+	}
+	if _, err := Parse(strings.TrimSuffix(raw, raw[strings.LastIndex(raw, "."):]), opts); err != ErrMalformedToken {
+		t.Fatalf("Unexpected error for malformed token: %v", err)
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	var claims ClaimSet
+	if err := json.Unmarshal([]byte(`{"aud":"a","scope":"x"}`), &claims); err != nil {
+		t.Fatalf("Error unmarshalling claims: %s", err)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "a" || claims.Custom["scope"] != "x" {
+		t.Fatalf("Unexpected claims: %#v", claims)
+	}
+	if err := json.Unmarshal([]byte(`{"aud":["a","b"]}`), &claims); err != nil {
+		t.Fatalf("Error unmarshalling claims: %s", err)
+	}
+	if !claims.Audience.Contains("b") {
+		t.Fatalf("Unexpected audience: %v", claims.Audience)
+	}
+	claims = ClaimSet{}
+	if err := json.Unmarshal([]byte(`{"aud":null}`), &claims); err != nil {
+		t.Fatalf("Error unmarshalling claims: %s", err)
+	}
+	if claims.Audience != nil || claims.Audience.Contains("") {
+		t.Fatalf("Expected no audience for null, got %#v", claims.Audience)
+	}
+
+	b, err := json.Marshal(&ClaimSet{Audience: Audience{"a"}})
+	if err != nil {
+		t.Fatalf("Error marshalling claims: %s", err)
+	}
+	if string(b) != `{"aud":"a"}` {
+		t.Fatalf("Unexpected serialization: %s", b)
+	}
+
+	if _, err := json.Marshal(&ClaimSet{Custom: map[string]interface{}{"exp": 1}}); err == nil {
+		t.Fatalf("Expected error for custom claim overriding a registered claim")
+	}
+}
@@ -0,0 +1,214 @@
+package libtrust
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// KeyDelegation describes the signing authority delegated to a key in a key
+// hierarchy. The embedded CertificateOptions customize its certificate;
+// MaxPathLen limits how many levels of keys may be delegated below it.
+//
+// Name constraints restrict the names of any certificate below the key.
+// Certificates below name constraints identify their key by common name and
+// subject key identifier only, as URIs without a host, such as key ID URIs,
+// fail verification under any name constraint. For the same reason URI
+// constraints are not supported, and URIs may not be requested in the
+// options of certificates below name constraints.
+type KeyDelegation struct {
+	CertificateOptions
+
+	// PermittedDNSDomains and ExcludedDNSDomains constrain DNS names.
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+	// PermittedIPRanges and ExcludedIPRanges constrain IP addresses.
+	PermittedIPRanges []*net.IPNet
+	ExcludedIPRanges  []*net.IPNet
+	// PermittedEmailAddresses and ExcludedEmailAddresses constrain email
+	// addresses, as mailboxes, hosts or domains.
+	PermittedEmailAddresses []string
+	ExcludedEmailAddresses  []string
+}
+
+// keyDelegationTemplate returns the certificate template of a key which
+// may sign certificates.
+func keyDelegationTemplate(key PublicKey, d *KeyDelegation, issuerChain []*x509.Certificate) (*x509.Certificate, error) {
+	if d == nil {
+		d = &KeyDelegation{}
+	}
+	info, err := keyHierarchyCertInfo(key, &d.CertificateOptions, issuerChain)
+	if err != nil {
+		return nil, err
+	}
+	info.isCA = true
+	template, err := generateCertTemplate(info)
+	if err != nil {
+		return nil, err
+	}
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	template.PermittedDNSDomains = d.PermittedDNSDomains
+	template.ExcludedDNSDomains = d.ExcludedDNSDomains
+	template.PermittedIPRanges = d.PermittedIPRanges
+	template.ExcludedIPRanges = d.ExcludedIPRanges
+	template.PermittedEmailAddresses = d.PermittedEmailAddresses
+	template.ExcludedEmailAddresses = d.ExcludedEmailAddresses
+	return template, nil
+}
+
+// keyHierarchyCertInfo returns the template information of a certificate
+// for the key issued with the issuer chain, leaving out URIs if the chain
+// has name constraints. An empty chain stands for a self-signed
+// certificate.
+func keyHierarchyCertInfo(key PublicKey, opts *CertificateOptions, issuerChain []*x509.Certificate) (*certTemplateInfo, error) {
+	constrained := false
+	for _, cert := range issuerChain {
+		if hasNameConstraints(cert) {
+			constrained = true
+			break
+		}
+	}
+	if constrained && opts != nil && len(opts.URIs) > 0 {
+		return nil, errors.New("URI subject alternative names cannot be verified below name constraints")
+	}
+	return &certTemplateInfo{
+		key:         key,
+		commonName:  key.KeyID(),
+		options:     opts,
+		omitKeyURIs: constrained,
+	}, nil
+}
+
+// hasNameConstraints returns whether the certificate constrains the names
+// of the certificates below it.
+func hasNameConstraints(cert *x509.Certificate) bool {
+	return len(cert.PermittedDNSDomains) > 0 || len(cert.ExcludedDNSDomains) > 0 ||
+		len(cert.PermittedIPRanges) > 0 || len(cert.ExcludedIPRanges) > 0 ||
+		len(cert.PermittedEmailAddresses) > 0 || len(cert.ExcludedEmailAddresses) > 0 ||
+		len(cert.PermittedURIDomains) > 0 || len(cert.ExcludedURIDomains) > 0
+}
+
+// signCertificate signs a template with the issuer key and certificate,
+// limiting its validity to that of the issuer.
+func signCertificate(template *x509.Certificate, key PublicKey, issuer PrivateKey, issuerCert *x509.Certificate) (*x509.Certificate, error) {
+	if issuerCert != nil {
+		issuerKey, err := FromCryptoPublicKey(issuerCert.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if issuerKey.KeyID() != issuer.KeyID() {
+			return nil, fmt.Errorf("issuer certificate is for key %s, not %s", issuerKey.KeyID(), issuer.KeyID())
+		}
+		if !issuerCert.IsCA {
+			return nil, errors.New("issuer certificate is not a CA certificate")
+		}
+		if template.NotAfter.After(issuerCert.NotAfter) {
+			template.NotAfter = issuerCert.NotAfter
+		}
+		if template.NotBefore.Before(issuerCert.NotBefore) {
+			template.NotBefore = issuerCert.NotBefore
+		}
+	} else {
+		issuerCert = template
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, issuerCert, key.CryptoPublicKey(), issuer.CryptoPrivateKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %s", err)
+	}
+	return cert, nil
+}
+
+// GenerateRootKeyCert creates a self-signed certificate making the key the
+// root of a key hierarchy, with the given delegation.
+func GenerateRootKeyCert(key PrivateKey, d *KeyDelegation) (*x509.Certificate, error) {
+	template, err := keyDelegationTemplate(key.PublicKey(), d, nil)
+	if err != nil {
+		return nil, err
+	}
+	return signCertificate(template, key.PublicKey(), key, nil)
+}
+
+// DelegateKey creates a certificate signed by the issuer key delegating
+// signing authority to another key. The issuer chain starts with the
+// certificate of the issuer key, followed by its issuers up to the root. The
+// delegated key may sign certificates for further keys, within the
+// constraints of its own delegation and those above it.
+func DelegateKey(issuer PrivateKey, issuerChain []*x509.Certificate, key PublicKey, d *KeyDelegation) (*x509.Certificate, error) {
+	if len(issuerChain) == 0 {
+		return nil, errors.New("no issuer certificate")
+	}
+	template, err := keyDelegationTemplate(key, d, issuerChain)
+	if err != nil {
+		return nil, err
+	}
+	return signCertificate(template, key, issuer, issuerChain[0])
+}
+
+// GenerateSigningKeyCert creates a certificate signed by the issuer key for
+// a leaf key which signs content but not certificates. The issuer chain
+// starts with the certificate of the issuer key, followed by its issuers up
+// to the root.
+func GenerateSigningKeyCert(issuer PrivateKey, issuerChain []*x509.Certificate, key PublicKey, opts *CertificateOptions) (*x509.Certificate, error) {
+	if len(issuerChain) == 0 {
+		return nil, errors.New("no issuer certificate")
+	}
+	info, err := keyHierarchyCertInfo(key, opts, issuerChain)
+	if err != nil {
+		return nil, err
+	}
+	template, err := generateCertTemplate(info)
+	if err != nil {
+		return nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	return signCertificate(template, key, issuer, issuerChain[0])
+}
+
+// GenerateKeyHierarchyChain builds the certificates of a key hierarchy in
+// which each of the given keys, starting with the root, delegates signing
+// authority to the next, and the last certifies the leaf key. Delegations
+// apply to the key at the same index and may be nil or shorter than keys.
+//
+// The chain is returned from the leaf to the root, as expected by
+// SignWithChain; verifiers should trust the root certificate, which is the
+// last in the chain.
+func GenerateKeyHierarchyChain(keys []PrivateKey, delegations []*KeyDelegation, leaf PublicKey, leafOpts *CertificateOptions) ([]*x509.Certificate, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("key hierarchy has no root key")
+	}
+	if len(delegations) > len(keys) {
+		return nil, errors.New("more delegations than keys in key hierarchy")
+	}
+	delegation := func(i int) *KeyDelegation {
+		if i < len(delegations) {
+			return delegations[i]
+		}
+		return nil
+	}
+
+	rootCert, err := GenerateRootKeyCert(keys[0], delegation(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate root certificate: %s", err)
+	}
+	chain := []*x509.Certificate{rootCert}
+	for i := 1; i < len(keys); i++ {
+		cert, err := DelegateKey(keys[i-1], chain, keys[i].PublicKey(), delegation(i))
+		if err != nil {
+			return nil, fmt.Errorf("failed to delegate to key %s: %s", keys[i].KeyID(), err)
+		}
+		chain = append([]*x509.Certificate{cert}, chain...)
+	}
+	leafCert, err := GenerateSigningKeyCert(keys[len(keys)-1], chain, leaf, leafOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate leaf certificate: %s", err)
+	}
+
+	return append([]*x509.Certificate{leafCert}, chain...), nil
+}
@@ -0,0 +1,130 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestKeyHierarchyChain(t *testing.T) {
+	keys := generateECTestKeys(t)
+	leafKey := keys[2]
+	delegations := []*KeyDelegation{
+		{
+			CertificateOptions:      CertificateOptions{MaxPathLen: 1},
+			PermittedEmailAddresses: []string{"example.com"},
+		},
+		{
+			CertificateOptions:      CertificateOptions{MaxPathLenZero: true},
+			PermittedEmailAddresses: []string{"team.example.com"},
+		},
+	}
+
+	chain, err := GenerateKeyHierarchyChain(keys[:2], delegations, leafKey.PublicKey(), &CertificateOptions{EmailAddresses: []string{"signer@team.example.com"}})
+	if err != nil {
+		t.Fatalf("Error generating chain: %s", err)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("Expected chain of 3 certificates, got %d", len(chain))
+	}
+	for i, key := range []PrivateKey{leafKey, keys[1], keys[0]} {
+		if certKey, err := CertificateIdentity(chain[i]); err != nil || certKey.KeyID() != key.KeyID() {
+			t.Fatalf("Unexpected key of certificate %d: %v", i, err)
+		}
+	}
+	if chain[0].IsCA || !chain[1].IsCA || !chain[1].MaxPathLenZero || chain[2].MaxPathLen != 1 {
+		t.Fatalf("Unexpected basic constraints in chain")
+	}
+
+	js, err := NewJSONSignature([]byte(`{"delegated":true}`))
+	if err != nil {
+		t.Fatalf("Error creating signature: %s", err)
+	}
+	if err := js.SignWithChain(leafKey, chain); err != nil {
+		t.Fatalf("Error signing with chain: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(chain[2])
+	if _, err := js.VerifyChains(roots); err != nil {
+		t.Fatalf("Error verifying chain: %s", err)
+	}
+
+	// Names outside the delegated constraints are rejected.
+	chain, err = GenerateKeyHierarchyChain(keys[:2], delegations, leafKey.PublicKey(), &CertificateOptions{EmailAddresses: []string{"signer@example.com"}})
+	if err != nil {
+		t.Fatalf("Error generating chain: %s", err)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{Roots: certPool(chain[2]), Intermediates: certPool(chain[1])}); err == nil {
+		t.Fatalf("Expected error verifying leaf outside name constraints")
+	}
+
+	// Delegating beyond the path length is rejected.
+	extraKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	chain, err = GenerateKeyHierarchyChain([]PrivateKey{keys[0], keys[1], extraKey}, delegations, leafKey.PublicKey(), nil)
+	if err != nil {
+		t.Fatalf("Error generating chain: %s", err)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{Roots: certPool(chain[3]), Intermediates: certPool(chain[1:3]...)}); err == nil {
+		t.Fatalf("Expected error verifying chain beyond path length")
+	}
+
+	if _, err := GenerateKeyHierarchyChain(nil, nil, leafKey.PublicKey(), nil); err == nil {
+		t.Fatalf("Expected error generating chain without keys")
+	}
+	if _, err := DelegateKey(keys[1], chain[3:], leafKey.PublicKey(), nil); err == nil {
+		t.Fatalf("Expected error delegating with mismatched issuer certificate")
+	}
+
+	// URIs cannot be requested below name constraints.
+	uri, err := url.Parse("https://example.com/signer")
+	if err != nil {
+		t.Fatalf("Error parsing URI: %s", err)
+	}
+	if _, err := GenerateKeyHierarchyChain(keys[:2], delegations, leafKey.PublicKey(), &CertificateOptions{URIs: []*url.URL{uri}}); err == nil {
+		t.Fatalf("Expected error requesting URI below name constraints")
+	}
+}
+
+func TestKeyHierarchyIdentityURIs(t *testing.T) {
+	keys := generateECTestKeys(t)
+	now := time.Now()
+	// An issuer certificate without key ID URIs nor name constraints.
+	issuerCert := generateTestCert(t, keys[0].PublicKey(), keys[0], nil, now.Add(-time.Minute), now.Add(time.Hour), true)
+
+	cert, err := DelegateKey(keys[0], []*x509.Certificate{issuerCert}, keys[1].PublicKey(), &KeyDelegation{
+		PermittedDNSDomains: []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Error delegating key: %s", err)
+	}
+	if len(cert.URIs) != 2 || cert.URIs[0].String() != KeyIDURI(keys[1].PublicKey()).String() {
+		t.Fatalf("Expected key identity URIs outside name constraints, got %v", cert.URIs)
+	}
+
+	leafCert, err := GenerateSigningKeyCert(keys[1], []*x509.Certificate{cert, issuerCert}, keys[2].PublicKey(), nil)
+	if err != nil {
+		t.Fatalf("Error generating signing key certificate: %s", err)
+	}
+	if len(leafCert.URIs) != 0 {
+		t.Fatalf("Expected no URIs below name constraints, got %v", leafCert.URIs)
+	}
+	if _, err := leafCert.Verify(x509.VerifyOptions{Roots: certPool(issuerCert), Intermediates: certPool(cert), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Fatalf("Error verifying signing key certificate: %s", err)
+	}
+
+	if _, err := GenerateSigningKeyCert(keys[1], nil, keys[2].PublicKey(), nil); err == nil {
+		t.Fatalf("Expected error generating signing key certificate without issuer chain")
+	}
+}
+
+func certPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
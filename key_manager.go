@@ -19,6 +19,7 @@ type ClientKeyManager struct {
 
 	clientLock sync.RWMutex
 	clients    []PublicKey
+	pins       *PinSet
 
 	configLock sync.Mutex
 	configs    []*tls.Config
@@ -65,8 +66,14 @@ func (c *ClientKeyManager) loadKeys() (err error) {
 		}
 	}
 
+	pins, err := NewPinSet(clients...)
+	if err != nil {
+		return fmt.Errorf("unable to pin authorized keys: %s", err)
+	}
+
 	c.clientLock.Lock()
 	c.clients = clients
+	c.pins = pins
 	c.clientLock.Unlock()
 
 	return nil
@@ -92,6 +99,24 @@ func (c *ClientKeyManager) RegisterTLSConfig(tlsConfig *tls.Config) error {
 	return nil
 }
 
+// RegisterPinnedTLSConfig registers a tls configuration to manager
+// such that client certificates are accepted if they are for any of
+// the client keys, without generating a client CA pool
+func (c *ClientKeyManager) RegisterPinnedTLSConfig(tlsConfig *tls.Config) {
+	tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	tlsConfig.ClientCAs = nil
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		c.clientLock.RLock()
+		pins := c.pins
+		c.clientLock.RUnlock()
+		return pins.VerifyPeerCertificate(rawCerts, verifiedChains)
+	}
+
+	c.configLock.Lock()
+	c.configs = append(c.configs, tlsConfig)
+	c.configLock.Unlock()
+}
+
 // NewIdentityAuthTLSConfig creates a tls.Config for the server to use for
 // libtrust identity authentication for the domain specified
 func NewIdentityAuthTLSConfig(trustKey PrivateKey, clients *ClientKeyManager, addr string, domain string) (*tls.Config, error) {
@@ -102,24 +127,49 @@ func NewIdentityAuthTLSConfig(trustKey PrivateKey, clients *ClientKeyManager, ad
 		return nil, err
 	}
 
-	// Generate cert
-	ips, domains, err := parseAddr(addr)
+	cert, err := identityServerCertificate(trustKey, addr, domain)
 	if err != nil {
 		return nil, err
 	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+
+	return tlsConfig, nil
+}
+
+// NewPinnedIdentityAuthTLSConfig creates a tls.Config for the server to use
+// for libtrust identity authentication for the domain specified, accepting
+// clients by the pins of their keys rather than a generated CA pool
+func NewPinnedIdentityAuthTLSConfig(trustKey PrivateKey, clients *ClientKeyManager, addr string, domain string) (*tls.Config, error) {
+	tlsConfig := newTLSConfig()
+	clients.RegisterPinnedTLSConfig(tlsConfig)
+
+	cert, err := identityServerCertificate(trustKey, addr, domain)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+
+	return tlsConfig, nil
+}
+
+// identityServerCertificate generates a self-signed server certificate for
+// the trust key, valid for the address and the domain clients expect
+func identityServerCertificate(trustKey PrivateKey, addr string, domain string) (tls.Certificate, error) {
+	ips, domains, err := parseAddr(addr)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
 	// add domain that it expects clients to use
 	domains = append(domains, domain)
 	x509Cert, err := GenerateSelfSignedServerCert(trustKey, domains, ips)
 	if err != nil {
-		return nil, fmt.Errorf("certificate generation error: %s", err)
+		return tls.Certificate{}, fmt.Errorf("certificate generation error: %s", err)
 	}
-	tlsConfig.Certificates = []tls.Certificate{{
+	return tls.Certificate{
 		Certificate: [][]byte{x509Cert.Raw},
 		PrivateKey:  trustKey.CryptoPrivateKey(),
 		Leaf:        x509Cert,
-	}}
-
-	return tlsConfig, nil
+	}, nil
 }
 
 // NewCertAuthTLSConfig creates a tls.Config for the server to use for
@@ -0,0 +1,405 @@
+package libtrust
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ASN.1 structures of RFC 6960 needed to parse basic OCSP responses.
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []ocspSingleResponse
+	Extensions     []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	KeyHash       []byte
+	SerialNumber  *big.Int
+}
+
+var oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+var ocspHashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, crypto.SHA1},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, crypto.SHA256},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}, crypto.SHA384},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}, crypto.SHA512},
+}
+
+var ocspSignatureAlgorithms = []struct {
+	oid       asn1.ObjectIdentifier
+	algorithm x509.SignatureAlgorithm
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, x509.ECDSAWithSHA256},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+}
+
+var (
+	errMissingOCSP          = errors.New("no stapled OCSP response")
+	errStaleOCSP            = errors.New("stapled OCSP response is stale")
+	errUnknownOCSP          = errors.New("OCSP responder does not know the certificate")
+	errExpiredOCSPResponder = errors.New("OCSP responder certificate is not valid at the verification time")
+)
+
+// OCSPStatus is the revocation status of a certificate given by an OCSP
+// response.
+type OCSPStatus int
+
+const (
+	// OCSPGood is used when the certificate is not revoked.
+	OCSPGood OCSPStatus = iota
+	// OCSPRevoked is used when the certificate has been revoked.
+	OCSPRevoked
+	// OCSPUnknown is used when the responder does not know the
+	// certificate.
+	OCSPUnknown
+)
+
+// OCSPResponse is a verified OCSP response for a single certificate.
+type OCSPResponse struct {
+	// Status is the revocation status of the certificate.
+	Status OCSPStatus
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber *big.Int
+	// ProducedAt is the time at which the response was signed.
+	ProducedAt time.Time
+	// ThisUpdate is the time at which the status was known to be correct.
+	ThisUpdate time.Time
+	// NextUpdate is the time after which the response is stale, zero if
+	// newer information is always available.
+	NextUpdate time.Time
+	// RevokedAt is the time of revocation of a revoked certificate.
+	RevokedAt time.Time
+	// Responder is the delegated responder certificate which signed the
+	// response, nil if the issuer signed it.
+	Responder *x509.Certificate
+}
+
+// ParseOCSPResponse parses a DER encoded OCSP response about a certificate
+// issued by the given issuer and checks that it is signed by the issuer or
+// by a responder certificate the issuer delegated OCSP signing to. The
+// validity period of a delegated responder certificate is not checked, as
+// the time of verification is not known; callers must check it against the
+// Responder certificate.
+func ParseOCSPResponse(der []byte, issuer *x509.Certificate) (*OCSPResponse, error) {
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil {
+		return nil, fmt.Errorf("malformed OCSP response: %s", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after OCSP response")
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("OCSP response has error status %d", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return nil, fmt.Errorf("unsupported OCSP response type %s", resp.Response.ResponseType)
+	}
+
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, fmt.Errorf("malformed OCSP response: %s", err)
+	}
+	var data ocspResponseData
+	if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &data); err != nil {
+		return nil, fmt.Errorf("malformed OCSP response data: %s", err)
+	}
+	if len(data.Responses) != 1 {
+		return nil, fmt.Errorf("OCSP response has %d statuses, expected 1", len(data.Responses))
+	}
+
+	signer, err := ocspSigner(basic.Certificates, issuer)
+	if err != nil {
+		return nil, err
+	}
+	algorithm := x509.UnknownSignatureAlgorithm
+	for _, alg := range ocspSignatureAlgorithms {
+		if alg.oid.Equal(basic.SignatureAlgorithm.Algorithm) {
+			algorithm = alg.algorithm
+			break
+		}
+	}
+	if err := signer.CheckSignature(algorithm, basic.TBSResponseData.FullBytes, basic.Signature.RightAlign()); err != nil {
+		return nil, fmt.Errorf("invalid OCSP response signature: %s", err)
+	}
+
+	single := data.Responses[0]
+	if err := checkOCSPCertID(single.CertID, issuer); err != nil {
+		return nil, err
+	}
+
+	response := &OCSPResponse{
+		SerialNumber: single.CertID.SerialNumber,
+		ProducedAt:   data.ProducedAt,
+		ThisUpdate:   single.ThisUpdate,
+		NextUpdate:   single.NextUpdate,
+	}
+	if signer != issuer {
+		response.Responder = signer
+	}
+	switch {
+	case bool(single.Good):
+		response.Status = OCSPGood
+	case bool(single.Unknown):
+		response.Status = OCSPUnknown
+	default:
+		response.Status = OCSPRevoked
+		response.RevokedAt = single.Revoked.RevocationTime
+	}
+	return response, nil
+}
+
+// ocspSigner returns the certificate whose key signed an OCSP response: the
+// issuer itself, or the first embedded certificate if it is issued by the
+// issuer for OCSP signing.
+func ocspSigner(certs []asn1.RawValue, issuer *x509.Certificate) (*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return issuer, nil
+	}
+	responder, err := x509.ParseCertificate(certs[0].FullBytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed OCSP responder certificate: %s", err)
+	}
+	if bytes.Equal(responder.Raw, issuer.Raw) {
+		return issuer, nil
+	}
+	if err := responder.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("OCSP responder certificate not issued by issuer: %s", err)
+	}
+	for _, usage := range responder.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return responder, nil
+		}
+	}
+	return nil, errors.New("OCSP responder certificate does not allow OCSP signing")
+}
+
+// checkOCSPCertID checks that a certificate ID refers to the given issuer.
+func checkOCSPCertID(id ocspCertID, issuer *x509.Certificate) error {
+	var hash crypto.Hash
+	for _, h := range ocspHashes {
+		if h.oid.Equal(id.HashAlgorithm.Algorithm) {
+			hash = h.hash
+			break
+		}
+	}
+	if hash == 0 || !hash.Available() {
+		return fmt.Errorf("unsupported OCSP certificate ID hash %s", id.HashAlgorithm.Algorithm)
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+	if !bytes.Equal(id.NameHash, nameHash) || !bytes.Equal(id.KeyHash, keyHash) {
+		return errors.New("OCSP response is for a different issuer")
+	}
+	return nil
+}
+
+// StapleOCSP attaches DER encoded OCSP responses for the certificates of
+// the x509 chain to the unprotected header of the signatures by the given
+// key. The responses are verified against the chain by VerifyChains.
+func (js *JSONSignature) StapleOCSP(keyID string, responses ...[]byte) error {
+	found := false
+	for i := range js.signatures {
+		if js.signatures[i].keyID() != keyID {
+			continue
+		}
+		if len(js.signatures[i].Header.Chain) == 0 {
+			return ErrMissingChain
+		}
+		for _, response := range responses {
+			js.signatures[i].Header.OCSP = append(js.signatures[i].Header.OCSP, base64.StdEncoding.EncodeToString(response))
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no signature by key %s", keyID)
+	}
+	return nil
+}
+
+// checkStapledOCSP checks the stapled responses of a signature against its
+// verified chains.
+func checkStapledOCSP(encoded []string, chains [][]*x509.Certificate, now time.Time, require bool) error {
+	responses := make([][]byte, len(encoded))
+	for i := range encoded {
+		der, err := base64.StdEncoding.DecodeString(encoded[i])
+		if err != nil {
+			return fmt.Errorf("invalid stapled OCSP response: %s", err)
+		}
+		responses[i] = der
+	}
+	return checkOCSPResponses(responses, chains, now, require)
+}
+
+// checkOCSPResponses checks OCSP responses against verified chains,
+// returning the error of the first chain if none of them passes.
+func checkOCSPResponses(responses [][]byte, chains [][]*x509.Certificate, now time.Time, require bool) error {
+	var firstErr error
+	for _, chain := range chains {
+		err := checkChainOCSP(responses, chain, now, require)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// checkChainOCSP checks that every response is a current response about a
+// certificate of the chain which is not revoked, signed by a responder whose
+// certificate is valid at the given time. If require is set, one of them
+// must be about the leaf certificate.
+func checkChainOCSP(responses [][]byte, chain []*x509.Certificate, now time.Time, require bool) error {
+	leafChecked := false
+	for _, der := range responses {
+		var (
+			response *OCSPResponse
+			cert     *x509.Certificate
+			err      error
+		)
+		for i := 0; i < len(chain)-1 && cert == nil; i++ {
+			response, err = ParseOCSPResponse(der, chain[i+1])
+			if err == nil && response.SerialNumber.Cmp(chain[i].SerialNumber) == 0 {
+				cert = chain[i]
+			}
+		}
+		if cert == nil {
+			if err == nil {
+				err = errors.New("stapled OCSP response is not about the chain")
+			}
+			return &RevocationUnavailableError{Certificate: chain[0], Err: err}
+		}
+
+		switch response.Status {
+		case OCSPRevoked:
+			return &RevokedError{Certificate: cert, RevokedAt: response.RevokedAt}
+		case OCSPUnknown:
+			return &RevocationUnavailableError{Certificate: cert, Err: errUnknownOCSP}
+		}
+		if now.Before(response.ThisUpdate) || (!response.NextUpdate.IsZero() && now.After(response.NextUpdate)) {
+			return &RevocationUnavailableError{Certificate: cert, Err: errStaleOCSP}
+		}
+		if r := response.Responder; r != nil && (now.Before(r.NotBefore) || now.After(r.NotAfter)) {
+			return &RevocationUnavailableError{Certificate: cert, Err: errExpiredOCSPResponder}
+		}
+		if cert == chain[0] {
+			leafChecked = true
+		}
+	}
+	if require && !leafChecked {
+		return &RevocationUnavailableError{Certificate: chain[0], Err: errMissingOCSP}
+	}
+	return nil
+}
+
+// OCSPVerifier checks OCSP responses stapled to TLS handshakes against the
+// verified chains of the peer. Since only servers staple responses, it is
+// meant for client configurations such as the one returned by
+// NewIdentityAuthTLSClientConfig.
+type OCSPVerifier struct {
+	// RequireStaple fails verification when the peer does not staple a
+	// response about its certificate.
+	RequireStaple bool
+
+	// Now returns the time used to determine whether a response is stale.
+	// It defaults to time.Now.
+	Now func() time.Time
+}
+
+// VerifyConnection checks the OCSP response stapled to a TLS handshake. It
+// has the signature of the VerifyConnection field of tls.Config. Nothing is
+// checked when the chains were not verified.
+func (v *OCSPVerifier) VerifyConnection(cs tls.ConnectionState) error {
+	if len(cs.VerifiedChains) == 0 {
+		return nil
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	var responses [][]byte
+	if len(cs.OCSPResponse) > 0 {
+		responses = append(responses, cs.OCSPResponse)
+	}
+	return checkOCSPResponses(responses, cs.VerifiedChains, now(), v.RequireStaple)
+}
+
+// ConfigureTLS adds checking of stapled OCSP responses to the given TLS
+// configuration after any VerifyConnection function already set.
+func (v *OCSPVerifier) ConfigureTLS(tlsConfig *tls.Config) {
+	previous := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if previous != nil {
+			if err := previous(cs); err != nil {
+				return err
+			}
+		}
+		return v.VerifyConnection(cs)
+	}
+}
@@ -0,0 +1,241 @@
+package libtrust
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust/testutil"
+)
+
+func TestStapledOCSP(t *testing.T) {
+	now := time.Now()
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating CA: %s", err)
+	}
+	intermediateKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	intermediate, err := testutil.GenerateIntermediate(intermediateKey.CryptoPublicKey(), caKey.CryptoPrivateKey(), ca)
+	if err != nil {
+		t.Fatalf("Error generating intermediate: %s", err)
+	}
+	signerKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	leaf := generateTestCert(t, signerKey.PublicKey(), intermediateKey, intermediate, now.Add(-time.Second), now.Add(time.Hour), false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	responder := testutil.NewOCSPResponder(intermediate, intermediateKey.CryptoPrivateKey().(crypto.Signer))
+	good, err := responder.Response(leaf)
+	if err != nil {
+		t.Fatalf("Error creating OCSP response: %s", err)
+	}
+	response, err := ParseOCSPResponse(good, intermediate)
+	if err != nil {
+		t.Fatalf("Error parsing OCSP response: %s", err)
+	}
+	if response.Status != OCSPGood || response.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Fatalf("Unexpected OCSP response: %+v", response)
+	}
+	if _, err := ParseOCSPResponse(good, ca); err == nil {
+		t.Fatalf("Expected error parsing OCSP response with wrong issuer")
+	}
+	tampered := append([]byte{}, good...)
+	tampered[len(tampered)-10] ^= 0xff
+	if _, err := ParseOCSPResponse(tampered, intermediate); err == nil {
+		t.Fatalf("Expected error parsing tampered OCSP response")
+	}
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(signerKey, []*x509.Certificate{leaf, intermediate}); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+
+	// Stapled responses are only checked when present unless required.
+	if _, err := js.VerifyChains(pool); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+	_, err = js.VerifyChainsWithOptions(ChainVerifyOptions{Roots: pool, RequireOCSP: true})
+	expectSignatureError(t, err, 0, signerKey.KeyID(), FailureRevoked)
+
+	if err := js.StapleOCSP(signerKey.KeyID(), good); err != nil {
+		t.Fatalf("Error stapling OCSP response: %s", err)
+	}
+	if err := js.StapleOCSP(intermediateKey.KeyID(), good); err == nil {
+		t.Fatalf("Expected error stapling OCSP response for unknown key")
+	}
+
+	// The response survives serialization.
+	jsonBytes, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	parsed, err := ParsePrettySignature(jsonBytes, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing signature: %s", err)
+	}
+	if _, err := parsed.VerifyChainsWithOptions(ChainVerifyOptions{Roots: pool, RequireOCSP: true}); err != nil {
+		t.Fatalf("Error verifying chains with stapled response: %s", err)
+	}
+
+	// Stale responses are rejected.
+	chains := [][]*x509.Certificate{{leaf, intermediate, ca}}
+	err = checkOCSPResponses([][]byte{good}, chains, now.Add(2*time.Hour), false)
+	var unavailableErr *RevocationUnavailableError
+	if !errors.As(err, &unavailableErr) || unavailableErr.Err != errStaleOCSP {
+		t.Fatalf("Expected stale OCSP response error, got %v", err)
+	}
+
+	// A revoked response fails verification without further options.
+	responder.Revoke(leaf.SerialNumber, now)
+	revoked, err := responder.Response(leaf)
+	if err != nil {
+		t.Fatalf("Error creating OCSP response: %s", err)
+	}
+	if err := js.StapleOCSP(signerKey.KeyID(), revoked); err != nil {
+		t.Fatalf("Error stapling OCSP response: %s", err)
+	}
+	_, err = js.VerifyChains(pool)
+	expectSignatureError(t, err, 0, signerKey.KeyID(), FailureRevoked)
+	var revokedErr *RevokedError
+	if !errors.As(err, &revokedErr) || !revokedErr.Certificate.Equal(leaf) {
+		t.Fatalf("Expected revoked error for leaf, got %v", err)
+	}
+
+	// Responses from another issuer do not match the chain.
+	caResponder := testutil.NewOCSPResponder(ca, caKey.CryptoPrivateKey().(crypto.Signer))
+	intermediateGood, err := caResponder.Response(intermediate)
+	if err != nil {
+		t.Fatalf("Error creating OCSP response: %s", err)
+	}
+	if err := checkOCSPResponses([][]byte{intermediateGood}, chains, now, false); err != nil {
+		t.Fatalf("Error checking intermediate response: %s", err)
+	}
+	if err := checkOCSPResponses([][]byte{intermediateGood}, chains, now, true); !errors.As(err, &unavailableErr) || unavailableErr.Err != errMissingOCSP {
+		t.Fatalf("Expected missing OCSP response error, got %v", err)
+	}
+	if err := checkOCSPResponses([][]byte{intermediateGood}, [][]*x509.Certificate{{leaf, ca}}, now, false); !errors.As(err, &unavailableErr) {
+		t.Fatalf("Expected error for response not about the chain, got %v", err)
+	}
+
+	// Responses stapled to TLS handshakes.
+	called := false
+	tlsConfig := &tls.Config{
+		VerifyConnection: func(tls.ConnectionState) error {
+			called = true
+			return nil
+		},
+	}
+	verifier := &OCSPVerifier{RequireStaple: true}
+	verifier.ConfigureTLS(tlsConfig)
+	if err := tlsConfig.VerifyConnection(tls.ConnectionState{VerifiedChains: chains, OCSPResponse: good}); err != nil || !called {
+		t.Fatalf("Error verifying stapled response: %v", err)
+	}
+	if err := tlsConfig.VerifyConnection(tls.ConnectionState{VerifiedChains: chains, OCSPResponse: revoked}); !errors.As(err, &revokedErr) {
+		t.Fatalf("Expected revoked error from connection verification, got %v", err)
+	}
+	if err := tlsConfig.VerifyConnection(tls.ConnectionState{VerifiedChains: chains}); !errors.As(err, &unavailableErr) {
+		t.Fatalf("Expected missing staple error, got %v", err)
+	}
+	verifier.RequireStaple = false
+	if err := tlsConfig.VerifyConnection(tls.ConnectionState{VerifiedChains: chains}); err != nil {
+		t.Fatalf("Error verifying connection without staple: %s", err)
+	}
+}
+
+func loadOCSPTestCert(t *testing.T, name string) *x509.Certificate {
+	certs, err := LoadCertificateBundle(filepath.Join("testdata", "ocsp", name))
+	if err != nil {
+		t.Fatalf("Error loading certificate: %s", err)
+	}
+	return certs[0]
+}
+
+func TestParseOpenSSLOCSPResponses(t *testing.T) {
+	ca := loadOCSPTestCert(t, "ca.pem")
+	leaf := loadOCSPTestCert(t, "leaf.pem")
+	revoked := loadOCSPTestCert(t, "revoked.pem")
+
+	delegated, err := ioutil.ReadFile(filepath.Join("testdata", "ocsp", "leaf-delegated.der"))
+	if err != nil {
+		t.Fatalf("Error reading OCSP response: %s", err)
+	}
+	response, err := ParseOCSPResponse(delegated, ca)
+	if err != nil {
+		t.Fatalf("Error parsing OCSP response: %s", err)
+	}
+	if response.Status != OCSPGood || response.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Fatalf("Unexpected OCSP response: %+v", response)
+	}
+	if response.Responder == nil || response.Responder.Subject.CommonName != "OCSP Test responder" {
+		t.Fatalf("Expected delegated responder certificate, got %+v", response.Responder)
+	}
+	if response.ThisUpdate.IsZero() || !response.NextUpdate.After(response.ThisUpdate) {
+		t.Fatalf("Unexpected OCSP response times: %+v", response)
+	}
+	if _, err := ParseOCSPResponse(delegated, leaf); err == nil {
+		t.Fatalf("Expected error parsing OCSP response with wrong issuer")
+	}
+
+	byIssuer, err := ioutil.ReadFile(filepath.Join("testdata", "ocsp", "revoked-issuer.der"))
+	if err != nil {
+		t.Fatalf("Error reading OCSP response: %s", err)
+	}
+	response, err = ParseOCSPResponse(byIssuer, ca)
+	if err != nil {
+		t.Fatalf("Error parsing OCSP response: %s", err)
+	}
+	if response.Status != OCSPRevoked || response.SerialNumber.Cmp(revoked.SerialNumber) != 0 {
+		t.Fatalf("Unexpected OCSP response: %+v", response)
+	}
+	if response.Responder != nil {
+		t.Fatalf("Expected no delegated responder, got %s", response.Responder.Subject)
+	}
+	if response.RevokedAt.IsZero() {
+		t.Fatalf("Expected revocation time")
+	}
+}
+
+func TestOCSPResponderValidity(t *testing.T) {
+	ca := loadOCSPTestCert(t, "ca.pem")
+	leaf := loadOCSPTestCert(t, "leaf.pem")
+	delegated, err := ioutil.ReadFile(filepath.Join("testdata", "ocsp", "leaf-delegated.der"))
+	if err != nil {
+		t.Fatalf("Error reading OCSP response: %s", err)
+	}
+	response, err := ParseOCSPResponse(delegated, ca)
+	if err != nil {
+		t.Fatalf("Error parsing OCSP response: %s", err)
+	}
+	chains := [][]*x509.Certificate{{leaf, ca}}
+	responses := [][]byte{delegated}
+
+	if err := checkOCSPResponses(responses, chains, response.ThisUpdate.Add(time.Hour), true); err != nil {
+		t.Fatalf("Error checking OCSP response: %s", err)
+	}
+	// The response is fresh, but its responder certificate has expired.
+	expired := response.Responder.NotAfter.Add(time.Hour)
+	err = checkOCSPResponses(responses, chains, expired, true)
+	var unavailable *RevocationUnavailableError
+	if !errors.As(err, &unavailable) || unavailable.Err != errExpiredOCSPResponder {
+		t.Fatalf("Expected expired responder error, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package libtrust
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Default limits used for the fields of ParseOptions left at zero.
+const (
+	DefaultMaxPayloadSize = 4 << 20
+	DefaultMaxSignatures  = 32
+	DefaultMaxChainLength = 8
+	DefaultMaxHeaderSize  = 64 << 10
+)
+
+// ParseOptions limits the resources used to parse signed content from
+// untrusted sources and rejects content which other parsers could read
+// differently: objects with duplicate members anywhere in the content,
+// including in protected headers, and unknown members in signature blocks.
+// A limit left at zero uses its default value and a negative limit is not
+// enforced. The package level parsing functions enforce no limits.
+type ParseOptions struct {
+	// MaxPayloadSize is the maximum size in bytes of the signed payload.
+	MaxPayloadSize int
+	// MaxSignatures is the maximum number of signatures.
+	MaxSignatures int
+	// MaxChainLength is the maximum number of certificates in the x509
+	// chain of a signature.
+	MaxChainLength int
+	// MaxHeaderSize is the maximum size in bytes of the unprotected header
+	// and of the decoded protected header of a signature.
+	MaxHeaderSize int
+}
+
+// LimitError is returned when parsing content which exceeds a limit of
+// ParseOptions.
+type LimitError struct {
+	// Limit names the exceeded limit.
+	Limit string
+	// Size is the size of the rejected content.
+	Size int
+	// Max is the value of the limit.
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d exceeds limit of %d", e.Limit, e.Size, e.Max)
+}
+
+// ParseJWS parses a JWS serialized JSON object into a Json Signature,
+// enforcing the options.
+func (o ParseOptions) ParseJWS(content []byte) (*JSONSignature, error) {
+	return parseJWS(content, &o)
+}
+
+// NewJSONSignature returns a new unsigned JWS from a json byte array and
+// optional serialized signatures, enforcing the options.
+func (o ParseOptions) NewJSONSignature(content []byte, signatures ...[]byte) (*JSONSignature, error) {
+	if err := o.checkSignatureCount(len(signatures)); err != nil {
+		return nil, err
+	}
+	return newJSONSignatureFromContent(content, &o, signatures)
+}
+
+// ParsePrettySignature parses a formatted signature into a JSON signature,
+// enforcing the options.
+func (o ParseOptions) ParsePrettySignature(content []byte, signatureKey string) (*JSONSignature, error) {
+	return parsePrettySignature(content, nil, signatureKey, &o)
+}
+
+// ParsePrettySignatureAt parses a formatted signature whose signatures
+// are embedded in the member at the given JSON pointer, enforcing the
+// options.
+func (o ParseOptions) ParsePrettySignatureAt(content []byte, pointer string) (*JSONSignature, error) {
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrettySignature(content, tokens[:len(tokens)-1], tokens[len(tokens)-1], &o)
+}
+
+func optionLimit(value, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func checkLimit(limit string, size, max int) error {
+	if max >= 0 && size > max {
+		return &LimitError{Limit: limit, Size: size, Max: max}
+	}
+	return nil
+}
+
+// checkInput rejects serialized content which is larger than the limits
+// can account for, before it is unmarshalled, or which has duplicate
+// members. Payloads may be base64 encoded and each signature block holds
+// both headers.
+func (o *ParseOptions) checkInput(content []byte) error {
+	if o == nil {
+		return nil
+	}
+	payload := optionLimit(o.MaxPayloadSize, DefaultMaxPayloadSize)
+	signatures := optionLimit(o.MaxSignatures, DefaultMaxSignatures)
+	header := optionLimit(o.MaxHeaderSize, DefaultMaxHeaderSize)
+	max := -1
+	if payload >= 0 && signatures >= 0 && header >= 0 {
+		max = 2*payload + signatures*4*header
+	}
+	if err := checkLimit("input size", len(content), max); err != nil {
+		return err
+	}
+	return checkDuplicateMembers(content)
+}
+
+// checkPayload rejects payloads which are too large or have duplicate
+// members.
+func (o *ParseOptions) checkPayload(payload []byte) error {
+	if o == nil {
+		return nil
+	}
+	if err := checkLimit("payload size", len(payload), optionLimit(o.MaxPayloadSize, DefaultMaxPayloadSize)); err != nil {
+		return err
+	}
+	return checkDuplicateMembers(payload)
+}
+
+func (o *ParseOptions) checkSignatureCount(count int) error {
+	if o == nil {
+		return nil
+	}
+	return checkLimit("signature count", count, optionLimit(o.MaxSignatures, DefaultMaxSignatures))
+}
+
+// parseSignatureBlock unmarshals a single serialized signature. When
+// options are given, unknown members are rejected and the headers are
+// checked against the limits.
+func (o *ParseOptions) parseSignatureBlock(content []byte) (jsParsedSignature, error) {
+	var parsed jsParsedSignature
+	if o == nil {
+		err := json.Unmarshal(content, &parsed)
+		return parsed, err
+	}
+
+	if err := checkDuplicateMembers(content); err != nil {
+		return parsed, err
+	}
+	var block struct {
+		Header    json.RawMessage `json:"header"`
+		Signature string          `json:"signature"`
+		Protected string          `json:"protected"`
+	}
+	if err := unmarshalStrict(content, &block); err != nil {
+		return parsed, fmt.Errorf("invalid signature block: %s", err)
+	}
+
+	header := optionLimit(o.MaxHeaderSize, DefaultMaxHeaderSize)
+	if err := checkLimit("header size", len(block.Header), header); err != nil {
+		return parsed, err
+	}
+	if len(block.Header) > 0 {
+		if err := unmarshalStrict(block.Header, &parsed.Header); err != nil {
+			return parsed, fmt.Errorf("invalid signature header: %s", err)
+		}
+	}
+	if err := checkLimit("chain length", len(parsed.Header.Chain), optionLimit(o.MaxChainLength, DefaultMaxChainLength)); err != nil {
+		return parsed, err
+	}
+
+	protected, err := joseBase64UrlDecode(block.Protected)
+	if err != nil {
+		return parsed, fmt.Errorf("base64 decode error: %s", err)
+	}
+	if err := checkLimit("protected header size", len(protected), header); err != nil {
+		return parsed, err
+	}
+	if err := checkDuplicateMembers(protected); err != nil {
+		return parsed, err
+	}
+	var protectedHeader map[string]interface{}
+	if err := json.Unmarshal(protected, &protectedHeader); err != nil {
+		return parsed, fmt.Errorf("error unmarshalling protected header: %s", err)
+	}
+
+	parsed.Signature = block.Signature
+	parsed.Protected = block.Protected
+	return parsed, nil
+}
+
+// unmarshalStrict unmarshals a single json value, rejecting unknown
+// members and trailing data.
+func unmarshalStrict(content []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after top-level value")
+	}
+	return nil
+}
+
+// checkDuplicateMembers returns an error if any object in the json
+// document has more than one member with the same name.
+func checkDuplicateMembers(content []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	return checkDuplicateMembersValue(dec)
+}
+
+func checkDuplicateMembersValue(dec *json.Decoder) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t {
+	case json.Delim('{'):
+		seen := make(map[string]struct{})
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := t.(string)
+			if _, ok := seen[key]; ok {
+				return fmt.Errorf("duplicate object member %q", key)
+			}
+			seen[key] = struct{}{}
+			if err := checkDuplicateMembersValue(dec); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for dec.More() {
+			if err := checkDuplicateMembersValue(dec); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	_, err = dec.Token()
+	return err
+}
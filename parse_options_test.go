@@ -0,0 +1,195 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func expectLimitError(t *testing.T, err error, limit string) {
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != limit {
+		t.Fatalf("Expected %s limit error, got %v", limit, err)
+	}
+}
+
+func TestParseOptionsLimits(t *testing.T) {
+	keys := make([]PrivateKey, 3)
+	for i := range keys {
+		var err error
+		keys[i], err = GenerateECP256PrivateKey()
+		if err != nil {
+			t.Fatalf("Error generating EC key: %s", err)
+		}
+	}
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	for _, key := range keys {
+		if err := js.Sign(key); err != nil {
+			t.Fatalf("Error signing content: %s", err)
+		}
+	}
+	jws, err := js.JWS()
+	if err != nil {
+		t.Fatalf("Error serializing JWS: %s", err)
+	}
+	pretty, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+
+	// The defaults accept ordinary content.
+	if _, err := (ParseOptions{}).ParseJWS(jws); err != nil {
+		t.Fatalf("Error parsing JWS: %s", err)
+	}
+	parsed, err := (ParseOptions{}).ParsePrettySignature(pretty, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing pretty signature: %s", err)
+	}
+	if _, err := parsed.Verify(); err != nil {
+		t.Fatalf("Error verifying signature: %s", err)
+	}
+
+	_, err = ParseOptions{MaxSignatures: 2}.ParseJWS(jws)
+	expectLimitError(t, err, "signature count")
+	_, err = ParseOptions{MaxSignatures: 2}.ParsePrettySignature(pretty, "signatures")
+	expectLimitError(t, err, "signature count")
+	_, err = ParseOptions{MaxPayloadSize: 64}.ParsePrettySignature(pretty, "signatures")
+	expectLimitError(t, err, "payload size")
+	_, err = ParseOptions{MaxPayloadSize: 1, MaxSignatures: 1, MaxHeaderSize: 1}.ParseJWS(jws)
+	expectLimitError(t, err, "input size")
+	_, err = ParseOptions{MaxHeaderSize: 64}.ParseJWS(jws)
+	expectLimitError(t, err, "header size")
+
+	// Negative limits are not enforced.
+	if _, err := (ParseOptions{MaxPayloadSize: -1, MaxSignatures: -1}).ParseJWS(jws); err != nil {
+		t.Fatalf("Error parsing JWS without limits: %s", err)
+	}
+
+	signatures, err := js.Signatures()
+	if err != nil {
+		t.Fatalf("Error getting signatures: %s", err)
+	}
+	payload, err := js.Payload()
+	if err != nil {
+		t.Fatalf("Error getting payload: %s", err)
+	}
+	_, err = ParseOptions{MaxSignatures: 1}.NewJSONSignature(payload, signatures...)
+	expectLimitError(t, err, "signature count")
+	if _, err := (ParseOptions{}).NewJSONSignature(payload, signatures...); err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+}
+
+func TestParseOptionsChainLength(t *testing.T) {
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	now := time.Now()
+	ca := generateTestCert(t, caKey.PublicKey(), caKey, nil, now.Add(-time.Hour), now.Add(time.Hour), true)
+	key, chain := generateTrustChain(t, caKey, ca)
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(key, chain); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	jws, err := js.JWS()
+	if err != nil {
+		t.Fatalf("Error serializing JWS: %s", err)
+	}
+
+	_, err = ParseOptions{MaxChainLength: len(chain) - 1}.ParseJWS(jws)
+	expectLimitError(t, err, "chain length")
+	parsed, err := ParseOptions{MaxChainLength: len(chain)}.ParseJWS(jws)
+	if err != nil {
+		t.Fatalf("Error parsing JWS: %s", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	if _, err := parsed.VerifyChains(pool); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+}
+
+func TestParseOptionsRejectAmbiguousContent(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	content := []byte(`{"name": "first", "config": {"user": "root"}, "name": "second"}`)
+	if _, err := NewJSONSignature(content); err != nil {
+		t.Fatalf("Unexpected error without options: %s", err)
+	}
+	if _, err := (ParseOptions{}).NewJSONSignature(content); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("Expected duplicate member error, got %v", err)
+	}
+
+	js, err := NewJSONSignature([]byte(`{"name": "first"}`))
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.Sign(key); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	jws, err := js.JWS()
+	if err != nil {
+		t.Fatalf("Error serializing JWS: %s", err)
+	}
+
+	// Duplicate payload member smuggled through the base64 payload.
+	var document map[string]interface{}
+	if err := json.Unmarshal(jws, &document); err != nil {
+		t.Fatalf("Error unmarshalling JWS: %s", err)
+	}
+	document["payload"] = joseBase64UrlEncode(content)
+	smuggled, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("Error marshalling JWS: %s", err)
+	}
+	if _, err := (ParseOptions{}).ParseJWS(smuggled); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("Expected duplicate member error, got %v", err)
+	}
+
+	// Duplicate protected header member.
+	signatures := document["signatures"].([]interface{})
+	signature := signatures[0].(map[string]interface{})
+	signature["protected"] = joseBase64UrlEncode([]byte(`{"formatLength":16,"formatLength":1,"formatTail":"fQ"}`))
+	document["payload"] = js.payload
+	duplicated, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("Error marshalling JWS: %s", err)
+	}
+	if _, err := (ParseOptions{}).ParseJWS(duplicated); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("Expected duplicate protected header member error, got %v", err)
+	}
+
+	// Unknown signature block member.
+	signatures[0] = map[string]interface{}{
+		"header":    map[string]interface{}{"alg": "ES256", "extra": true},
+		"signature": "",
+		"protected": "e30",
+	}
+	unknown, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("Error marshalling JWS: %s", err)
+	}
+	if _, err := (ParseOptions{}).ParseJWS(unknown); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("Expected unknown member error, got %v", err)
+	}
+	if _, err := ParseJWS(unknown); err != nil {
+		t.Fatalf("Unexpected error without options: %s", err)
+	}
+}
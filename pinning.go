@@ -0,0 +1,134 @@
+package libtrust
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPinMismatch is used when the key of a peer certificate is not pinned.
+var ErrPinMismatch = errors.New("peer key is not pinned")
+
+// SPKIPin returns the pin of a public key: the base64 encoded SHA-256 hash
+// of its DER encoded subject public key info, as used by RFC 7469.
+func SPKIPin(key PublicKey) (string, error) {
+	spki, err := x509.MarshalPKIXPublicKey(key.CryptoPublicKey())
+	if err != nil {
+		return "", err
+	}
+	return spkiPin(spki), nil
+}
+
+func spkiPin(spki []byte) string {
+	hash := sha256.Sum256(spki)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// PinSet verifies TLS peers by the key of their certificate rather than by
+// certificate chains. The certificates of pinned peers may be self-signed,
+// expired or issued by anyone, as the handshake proves that the peer holds
+// the pinned key.
+type PinSet struct {
+	pinLock sync.RWMutex
+	pins    map[string]bool
+}
+
+// NewPinSet returns a set pinning the given keys.
+func NewPinSet(keys ...PublicKey) (*PinSet, error) {
+	p := &PinSet{pins: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		if err := p.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Add pins a key.
+func (p *PinSet) Add(key PublicKey) error {
+	pin, err := SPKIPin(key)
+	if err != nil {
+		return err
+	}
+	p.AddPin(pin)
+	return nil
+}
+
+// AddPin adds a pin as returned by SPKIPin.
+func (p *PinSet) AddPin(pin string) {
+	p.pinLock.Lock()
+	defer p.pinLock.Unlock()
+	p.pins[pin] = true
+}
+
+// Contains returns whether the key of the certificate is pinned.
+func (p *PinSet) Contains(cert *x509.Certificate) bool {
+	p.pinLock.RLock()
+	defer p.pinLock.RUnlock()
+	return p.pins[spkiPin(cert.RawSubjectPublicKeyInfo)]
+}
+
+// VerifyPeerCertificate checks that the leaf certificate of the peer is for
+// a pinned key. It has the signature of the VerifyPeerCertificate field of
+// tls.Config and ignores the verified chains.
+func (p *PinSet) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer did not present a certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse peer certificate: %s", err)
+	}
+	if !p.Contains(cert) {
+		return ErrPinMismatch
+	}
+	return nil
+}
+
+// ConfigureServerTLS makes a server configuration require client
+// certificates for pinned keys instead of verifying them against
+// certificate authorities.
+func (p *PinSet) ConfigureServerTLS(tlsConfig *tls.Config) {
+	tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	tlsConfig.ClientCAs = nil
+	tlsConfig.VerifyPeerCertificate = p.VerifyPeerCertificate
+}
+
+// ConfigureClientTLS makes a client configuration accept server
+// certificates for pinned keys instead of verifying them against
+// certificate authorities. Chain and host name verification are disabled.
+func (p *PinSet) ConfigureClientTLS(tlsConfig *tls.Config) {
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.RootCAs = nil
+	tlsConfig.VerifyPeerCertificate = p.VerifyPeerCertificate
+}
+
+// NewPinnedIdentityAuthTLSClientConfig returns a client configuration
+// authenticating with a self-signed certificate for the trust key and
+// accepting servers with any of the given keys.
+func NewPinnedIdentityAuthTLSClientConfig(trustKey PrivateKey, serverKeys []PublicKey, serverName string) (*tls.Config, error) {
+	pins, err := NewPinSet(serverKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := newTLSConfig()
+	tlsConfig.ServerName = serverName
+	pins.ConfigureClientTLS(tlsConfig)
+
+	x509Cert, err := GenerateSelfSignedClientCert(trustKey)
+	if err != nil {
+		return nil, fmt.Errorf("certificate generation error: %s", err)
+	}
+	tlsConfig.Certificates = []tls.Certificate{{
+		Certificate: [][]byte{x509Cert.Raw},
+		PrivateKey:  trustKey.CryptoPrivateKey(),
+		Leaf:        x509Cert,
+	}}
+
+	return tlsConfig, nil
+}
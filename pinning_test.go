@@ -0,0 +1,146 @@
+package libtrust
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testHandshake performs a TLS handshake between the given configurations
+// and returns the errors of the server and the client.
+func testHandshake(serverConfig, clientConfig *tls.Config) (serverErr, clientErr error) {
+	serverConn, clientConn := net.Pipe()
+	server := tls.Server(serverConn, serverConfig)
+	client := tls.Client(clientConn, clientConfig)
+
+	serverErrs := make(chan error, 1)
+	go func() {
+		serverErrs <- server.Handshake()
+		serverConn.Close()
+	}()
+	clientErr = client.Handshake()
+	if clientErr == nil {
+		// Wait for the server to verify the client certificate.
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		client.Read(make([]byte, 1))
+	}
+	clientConn.Close()
+	return <-serverErrs, clientErr
+}
+
+func TestPinnedIdentityAuthTLS(t *testing.T) {
+	serverKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	clientKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "libtrust-pinning")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := SavePublicKey(filepath.Join(dir, "client.json"), clientKey.PublicKey()); err != nil {
+		t.Fatalf("Error saving client key: %s", err)
+	}
+	clients, err := NewClientKeyManager(serverKey, "", dir)
+	if err != nil {
+		t.Fatalf("Error creating client key manager: %s", err)
+	}
+
+	serverConfig, err := NewPinnedIdentityAuthTLSConfig(serverKey, clients, "127.0.0.1:8443", "localhost")
+	if err != nil {
+		t.Fatalf("Error creating server config: %s", err)
+	}
+	clientConfig, err := NewPinnedIdentityAuthTLSClientConfig(clientKey, []PublicKey{serverKey.PublicKey()}, "localhost")
+	if err != nil {
+		t.Fatalf("Error creating client config: %s", err)
+	}
+	if serverErr, clientErr := testHandshake(serverConfig, clientConfig); serverErr != nil || clientErr != nil {
+		t.Fatalf("Error in pinned handshake: server %v, client %v", serverErr, clientErr)
+	}
+
+	// An unpinned client is rejected by the server.
+	otherClientConfig, err := NewPinnedIdentityAuthTLSClientConfig(otherKey, []PublicKey{serverKey.PublicKey()}, "localhost")
+	if err != nil {
+		t.Fatalf("Error creating client config: %s", err)
+	}
+	if serverErr, _ := testHandshake(serverConfig, otherClientConfig); serverErr == nil {
+		t.Fatalf("Expected server to reject unpinned client")
+	}
+
+	// An unpinned server is rejected by the client.
+	otherServerConfig, err := NewPinnedIdentityAuthTLSConfig(otherKey, clients, "127.0.0.1:8443", "localhost")
+	if err != nil {
+		t.Fatalf("Error creating server config: %s", err)
+	}
+	if _, clientErr := testHandshake(otherServerConfig, clientConfig); clientErr == nil {
+		t.Fatalf("Expected client to reject unpinned server")
+	}
+}
+
+func TestPinSetExpiredCertificate(t *testing.T) {
+	key, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	cert, err := GenerateSelfSignedServerCertWithOptions(key, []string{"example.com"}, nil, &CertificateOptions{
+		NotBefore: time.Now().Add(-2 * time.Hour),
+		NotAfter:  time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err)
+	}
+
+	pins, err := NewPinSet(key.PublicKey())
+	if err != nil {
+		t.Fatalf("Error creating pin set: %s", err)
+	}
+	if err := pins.VerifyPeerCertificate([][]byte{cert.Raw}, nil); err != nil {
+		t.Fatalf("Error verifying expired pinned certificate: %s", err)
+	}
+
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	otherPins, err := NewPinSet(otherKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Error creating pin set: %s", err)
+	}
+	if err := otherPins.VerifyPeerCertificate([][]byte{cert.Raw}, nil); err != ErrPinMismatch {
+		t.Fatalf("Expected pin mismatch error, got %v", err)
+	}
+	pin, err := SPKIPin(key.PublicKey())
+	if err != nil {
+		t.Fatalf("Error computing pin: %s", err)
+	}
+	otherPins.AddPin(pin)
+	if !otherPins.Contains(cert) {
+		t.Fatalf("Expected pin to be added")
+	}
+
+	// Handshakes succeed with an expired certificate for a pinned key.
+	serverConfig := newTLSConfig()
+	serverConfig.Certificates = []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key.CryptoPrivateKey()}}
+	clientConfig := newTLSConfig()
+	pins.ConfigureClientTLS(clientConfig)
+	if _, clientErr := testHandshake(serverConfig, clientConfig); clientErr != nil {
+		t.Fatalf("Error in handshake with expired pinned certificate: %s", clientErr)
+	}
+	if err := pins.VerifyPeerCertificate(nil, [][]*x509.Certificate{{cert}}); err == nil {
+		t.Fatalf("Expected error without peer certificate")
+	}
+}
@@ -0,0 +1,264 @@
+package libtrust
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// RevocationChecker checks whether any certificate of verified x509 chains
+// has been revoked. An error is returned unless at least one of the chains
+// is known not to be revoked.
+//
+// Checkers which also have a CheckRevocationAt method, such as CRLStore,
+// are given the time at which chains are verified, so that chains verified
+// at a past time are checked as of that time.
+type RevocationChecker interface {
+	CheckRevocation(chains [][]*x509.Certificate) error
+}
+
+// revocationCheckerAt is a RevocationChecker which checks chains as of a
+// given time.
+type revocationCheckerAt interface {
+	CheckRevocationAt(chains [][]*x509.Certificate, at time.Time) error
+}
+
+// checkRevocation checks chains with the given checker as of the given
+// time if the checker supports it and the time is set.
+func checkRevocation(checker RevocationChecker, chains [][]*x509.Certificate, at time.Time) error {
+	if checkerAt, ok := checker.(revocationCheckerAt); ok && !at.IsZero() {
+		return checkerAt.CheckRevocationAt(chains, at)
+	}
+	return checker.CheckRevocation(chains)
+}
+
+// RevocationPolicy determines how a missing or stale revocation list is
+// handled. Certificates listed as revoked are rejected under either policy,
+// even by a stale list.
+type RevocationPolicy int
+
+const (
+	// FailClosed rejects certificates whose revocation status cannot be
+	// determined.
+	FailClosed RevocationPolicy = iota
+	// FailOpen accepts certificates whose revocation status cannot be
+	// determined.
+	FailOpen
+)
+
+// RevokedError is returned when a certificate has been revoked.
+type RevokedError struct {
+	// Certificate is the revoked certificate.
+	Certificate *x509.Certificate
+	// RevokedAt is the time of revocation.
+	RevokedAt time.Time
+}
+
+func (e *RevokedError) Error() string {
+	return fmt.Sprintf("certificate %q with serial %s was revoked at %s", e.Certificate.Subject.CommonName, e.Certificate.SerialNumber, e.RevokedAt.Format(time.RFC3339))
+}
+
+// RevocationUnavailableError is returned by fail closed checks when the
+// revocation status of a certificate cannot be determined.
+type RevocationUnavailableError struct {
+	// Certificate is the certificate which could not be checked.
+	Certificate *x509.Certificate
+	// Err is the reason the status is unavailable.
+	Err error
+}
+
+func (e *RevocationUnavailableError) Error() string {
+	return fmt.Sprintf("revocation status of certificate %q unavailable: %s", e.Certificate.Subject.CommonName, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RevocationUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errMissingCRL = errors.New("no revocation list for issuer")
+	errStaleCRL   = errors.New("revocation list is stale")
+)
+
+// CRLStore is a RevocationChecker using certificate revocation lists
+// loaded from files or memory, cached by issuer. Every certificate of a
+// chain other than its root is checked against the list of its issuer. The
+// zero value is an empty store failing closed.
+type CRLStore struct {
+	// Policy determines how certificates are handled when the list of
+	// their issuer is missing, stale or not signed by the issuer.
+	Policy RevocationPolicy
+
+	// Now returns the time used to determine whether a list is stale. It
+	// defaults to time.Now.
+	Now func() time.Time
+
+	crlLock sync.RWMutex
+	crls    map[string][]*x509.RevocationList
+}
+
+// NewCRLStore returns an empty store with the given policy.
+func NewCRLStore(policy RevocationPolicy) *CRLStore {
+	return &CRLStore{
+		Policy: policy,
+		Now:    time.Now,
+		crls:   make(map[string][]*x509.RevocationList),
+	}
+}
+
+// AddCRL adds revocation lists, PEM encoded or a single DER encoded list,
+// to the store. The signature of a list is checked against the issuer
+// when the list is used, so lists may be added before their issuer is
+// known. Older lists from the same issuer are kept so that a list can be
+// matched with the right issuer certificate, and the most recent matching
+// one is used.
+func (s *CRLStore) AddCRL(content []byte) error {
+	var crls []*x509.RevocationList
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("unable to parse revocation list: %s", err)
+		}
+		crls = append(crls, crl)
+	}
+	if len(crls) == 0 {
+		crl, err := x509.ParseRevocationList(content)
+		if err != nil {
+			return fmt.Errorf("unable to parse revocation list: %s", err)
+		}
+		crls = append(crls, crl)
+	}
+
+	s.crlLock.Lock()
+	defer s.crlLock.Unlock()
+	if s.crls == nil {
+		s.crls = make(map[string][]*x509.RevocationList)
+	}
+	for _, crl := range crls {
+		issuer := string(crl.RawIssuer)
+		s.crls[issuer] = append(s.crls[issuer], crl)
+	}
+	return nil
+}
+
+// LoadCRLFile adds the revocation lists in the given file to the store.
+func (s *CRLStore) LoadCRLFile(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return s.AddCRL(content)
+}
+
+// now returns the current time according to the store.
+func (s *CRLStore) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// currentCRL returns the most recent list signed by issuer for the
+// certificate. A stale list at the given time is returned along with
+// errStaleCRL, as its entries remain revoked.
+func (s *CRLStore) currentCRL(cert, issuer *x509.Certificate, at time.Time) (*x509.RevocationList, error) {
+	s.crlLock.RLock()
+	candidates := s.crls[string(cert.RawIssuer)]
+	s.crlLock.RUnlock()
+
+	var current *x509.RevocationList
+	for _, crl := range candidates {
+		if crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		if current == nil || crl.ThisUpdate.After(current.ThisUpdate) {
+			current = crl
+		}
+	}
+	if current == nil {
+		return nil, errMissingCRL
+	}
+	if !current.NextUpdate.IsZero() && at.After(current.NextUpdate) {
+		return current, errStaleCRL
+	}
+	return current, nil
+}
+
+// checkChain checks every certificate of a chain other than its root as of
+// the given time. Certificates listed by the most recent list are rejected
+// even if it is stale; the policy only decides on certificates whose status
+// is missing or stale.
+func (s *CRLStore) checkChain(chain []*x509.Certificate, at time.Time) error {
+	for i := 0; i < len(chain)-1; i++ {
+		cert := chain[i]
+		crl, err := s.currentCRL(cert, chain[i+1], at)
+		if crl != nil {
+			for _, entry := range crl.RevokedCertificateEntries {
+				if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return &RevokedError{Certificate: cert, RevokedAt: entry.RevocationTime}
+				}
+			}
+		}
+		if err != nil && s.Policy != FailOpen {
+			return &RevocationUnavailableError{Certificate: cert, Err: err}
+		}
+	}
+	return nil
+}
+
+// CheckRevocation checks the given verified chains, returning the error
+// of the first chain if none of them passes.
+func (s *CRLStore) CheckRevocation(chains [][]*x509.Certificate) error {
+	return s.CheckRevocationAt(chains, s.now())
+}
+
+// CheckRevocationAt checks the given verified chains as of the given time,
+// which determines whether lists are stale.
+func (s *CRLStore) CheckRevocationAt(chains [][]*x509.Certificate, at time.Time) error {
+	var firstErr error
+	for _, chain := range chains {
+		err := s.checkChain(chain, at)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// VerifyPeerCertificate checks the revocation of the chains verified
+// during a TLS handshake. It has the signature of the VerifyPeerCertificate
+// field of tls.Config. Nothing is checked when the chains were not
+// verified.
+func (s *CRLStore) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(verifiedChains) == 0 {
+		return nil
+	}
+	return s.CheckRevocation(verifiedChains)
+}
+
+// ConfigureTLS adds revocation checking of peer certificates to the given
+// TLS configuration, such as one returned by NewCertAuthTLSConfig, after
+// any VerifyPeerCertificate function already set.
+func (s *CRLStore) ConfigureTLS(tlsConfig *tls.Config) {
+	previous := tlsConfig.VerifyPeerCertificate
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if previous != nil {
+			if err := previous(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		return s.VerifyPeerCertificate(rawCerts, verifiedChains)
+	}
+}
@@ -0,0 +1,214 @@
+package libtrust
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust/testutil"
+)
+
+func generateTestCRL(t *testing.T, issuer *x509.Certificate, issuerKey PrivateKey, thisUpdate time.Time, revoked ...*big.Int) []byte {
+	template := &x509.RevocationList{
+		Number:     big.NewInt(thisUpdate.Unix()),
+		ThisUpdate: thisUpdate,
+		NextUpdate: thisUpdate.Add(time.Hour),
+	}
+	for _, serial := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: thisUpdate,
+		})
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, issuer, issuerKey.CryptoPrivateKey().(crypto.Signer))
+	if err != nil {
+		t.Fatalf("Error creating revocation list: %s", err)
+	}
+	return crl
+}
+
+func TestCRLStore(t *testing.T) {
+	now := time.Now()
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating CA: %s", err)
+	}
+	intermediateKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	intermediate, err := testutil.GenerateIntermediate(intermediateKey.CryptoPublicKey(), caKey.CryptoPrivateKey(), ca)
+	if err != nil {
+		t.Fatalf("Error generating intermediate: %s", err)
+	}
+	signerKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	leaf := generateTestCert(t, signerKey.PublicKey(), intermediateKey, intermediate, now.Add(-time.Second), now.Add(time.Hour), false)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(signerKey, []*x509.Certificate{leaf, intermediate}); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+
+	store := NewCRLStore(FailClosed)
+	opts := ChainVerifyOptions{Roots: pool, Revocation: store}
+
+	_, err = js.VerifyChainsWithOptions(opts)
+	expectSignatureError(t, err, 0, signerKey.KeyID(), FailureRevoked)
+	var unavailableErr *RevocationUnavailableError
+	if !errors.As(err, &unavailableErr) || !unavailableErr.Certificate.Equal(leaf) {
+		t.Fatalf("Expected revocation unavailable error for leaf, got %v", err)
+	}
+
+	store.Policy = FailOpen
+	if _, err := js.VerifyChainsWithOptions(opts); err != nil {
+		t.Fatalf("Error verifying chains failing open: %s", err)
+	}
+	store.Policy = FailClosed
+
+	// Load current lists from a PEM file.
+	dir, err := ioutil.TempDir("", "libtrust-crl")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	crlFile := filepath.Join(dir, "crl.pem")
+	var crlPEM []byte
+	for _, der := range [][]byte{
+		generateTestCRL(t, ca, caKey, now.Add(-time.Minute)),
+		generateTestCRL(t, intermediate, intermediateKey, now.Add(-time.Minute)),
+	} {
+		crlPEM = append(crlPEM, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})...)
+	}
+	if err := ioutil.WriteFile(crlFile, crlPEM, 0600); err != nil {
+		t.Fatalf("Error writing revocation lists: %s", err)
+	}
+	if err := store.LoadCRLFile(crlFile); err != nil {
+		t.Fatalf("Error loading revocation lists: %s", err)
+	}
+	if _, err := js.VerifyChainsWithOptions(opts); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+
+	// A list signed by another key is ignored.
+	if err := store.AddCRL(generateTestCRL(t, intermediate, signerKey, now, leaf.SerialNumber)); err != nil {
+		t.Fatalf("Error adding revocation list: %s", err)
+	}
+	if _, err := js.VerifyChainsWithOptions(opts); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+
+	// A newer list revokes the leaf.
+	if err := store.AddCRL(generateTestCRL(t, intermediate, intermediateKey, now, leaf.SerialNumber)); err != nil {
+		t.Fatalf("Error adding revocation list: %s", err)
+	}
+	_, err = js.VerifyChainsWithOptions(opts)
+	expectSignatureError(t, err, 0, signerKey.KeyID(), FailureRevoked)
+	var revokedErr *RevokedError
+	if !errors.As(err, &revokedErr) || !revokedErr.Certificate.Equal(leaf) {
+		t.Fatalf("Expected revoked error for leaf, got %v", err)
+	}
+
+	// Stale lists leave the status unavailable.
+	store.Now = func() time.Time { return now.Add(2 * time.Hour) }
+	if err := store.CheckRevocation([][]*x509.Certificate{{intermediate, ca}}); !errors.As(err, &unavailableErr) {
+		t.Fatalf("Expected revocation unavailable error for stale list, got %v", err)
+	}
+
+	// Certificates revoked by a stale list stay revoked when failing open.
+	store.Policy = FailOpen
+	if err := store.CheckRevocation([][]*x509.Certificate{{leaf, intermediate, ca}}); !errors.As(err, &revokedErr) || !revokedErr.Certificate.Equal(leaf) {
+		t.Fatalf("Expected revoked error for leaf listed by a stale list, got %v", err)
+	}
+	if err := store.CheckRevocation([][]*x509.Certificate{{intermediate, ca}}); err != nil {
+		t.Fatalf("Error checking stale list failing open: %s", err)
+	}
+	store.Policy = FailClosed
+	store.Now = time.Now
+
+	// Staleness is judged at the verification time rather than the store's.
+	intermediateChains := [][]*x509.Certificate{{intermediate, ca}}
+	if err := store.CheckRevocationAt(intermediateChains, now.Add(2*time.Hour)); !errors.As(err, &unavailableErr) {
+		t.Fatalf("Expected revocation unavailable error for list stale at verification time, got %v", err)
+	}
+	if err := checkRevocation(store, intermediateChains, now.Add(2*time.Hour)); !errors.As(err, &unavailableErr) {
+		t.Fatalf("Expected verification time to be passed to the store, got %v", err)
+	}
+	store.Now = func() time.Time { return now.Add(2 * time.Hour) }
+	if err := checkRevocation(store, intermediateChains, now); err != nil {
+		t.Fatalf("Error checking list fresh at verification time: %s", err)
+	}
+	store.Now = time.Now
+
+	chains := [][]*x509.Certificate{{leaf, intermediate, ca}}
+	if err := store.VerifyPeerCertificate(nil, chains); !errors.As(err, &revokedErr) {
+		t.Fatalf("Expected revoked error from peer verification, got %v", err)
+	}
+
+	called := false
+	tlsConfig := &tls.Config{
+		VerifyPeerCertificate: func([][]byte, [][]*x509.Certificate) error {
+			called = true
+			return nil
+		},
+	}
+	store.ConfigureTLS(tlsConfig)
+	if err := tlsConfig.VerifyPeerCertificate(nil, chains); !errors.As(err, &revokedErr) || !called {
+		t.Fatalf("Expected revoked error after previous verification, got %v", err)
+	}
+	if err := tlsConfig.VerifyPeerCertificate(nil, [][]*x509.Certificate{{intermediate, ca}}); err != nil {
+		t.Fatalf("Error verifying intermediate: %s", err)
+	}
+}
+
+func TestCRLStoreZeroValue(t *testing.T) {
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca, err := testutil.GenerateTrustCA(caKey.CryptoPublicKey(), caKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error generating CA: %s", err)
+	}
+	signerKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	now := time.Now()
+	leaf := generateTestCert(t, signerKey.PublicKey(), caKey, ca, now.Add(-time.Second), now.Add(time.Hour), false)
+	chains := [][]*x509.Certificate{{leaf, ca}}
+
+	var store CRLStore
+	var unavailableErr *RevocationUnavailableError
+	if err := store.CheckRevocation(chains); !errors.As(err, &unavailableErr) {
+		t.Fatalf("Expected revocation unavailable error from empty store, got %v", err)
+	}
+	if err := store.AddCRL(generateTestCRL(t, ca, caKey, now.Add(-time.Minute), leaf.SerialNumber)); err != nil {
+		t.Fatalf("Error adding revocation list: %s", err)
+	}
+	var revokedErr *RevokedError
+	if err := store.CheckRevocation(chains); !errors.As(err, &revokedErr) {
+		t.Fatalf("Expected revoked error, got %v", err)
+	}
+}
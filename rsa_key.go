@@ -57,9 +57,18 @@ func (k *rsaPublicKey) Verify(data io.Reader, alg string, signature []byte) erro
 	if err != nil {
 		return fmt.Errorf("error reading data to sign: %s", err)
 	}
-	hash := hasher.Sum(nil)
 
-	err = rsa.VerifyPKCS1v15(k.PublicKey, sigAlg.HashID(), hash, signature)
+	return k.verifyDigest(sigAlg, hasher.Sum(nil), signature)
+}
+
+// verifyDigest verifies the signature of an already computed hash of the
+// signed data.
+func (k *rsaPublicKey) verifyDigest(sigAlg *signatureAlgorithm, hash, signature []byte) error {
+	if _, err := rsaSignatureAlgorithmByName(sigAlg.HeaderParam()); err != nil {
+		return fmt.Errorf("unable to verify Signature: %s", err)
+	}
+
+	err := rsa.VerifyPKCS1v15(k.PublicKey, sigAlg.HashID(), hash, signature)
 	if err != nil {
 		return fmt.Errorf("invalid %s signature: %s", sigAlg.HeaderParam(), err)
 	}
@@ -208,11 +217,10 @@ func (k *rsaPrivateKey) Sign(data io.Reader, hashID crypto.Hash) (signature []by
 	if err != nil {
 		return nil, "", fmt.Errorf("error reading data to sign: %s", err)
 	}
-	hash := hasher.Sum(nil)
 
-	signature, err = rsa.SignPKCS1v15(rand.Reader, k.PrivateKey, sigAlg.HashID(), hash)
+	signature, err = k.signDigest(sigAlg, hasher.Sum(nil))
 	if err != nil {
-		return nil, "", fmt.Errorf("error producing signature: %s", err)
+		return nil, "", err
 	}
 
 	alg = sigAlg.HeaderParam()
@@ -220,6 +228,22 @@ func (k *rsaPrivateKey) Sign(data io.Reader, hashID crypto.Hash) (signature []by
 	return
 }
 
+// signatureAlgorithmForHash returns the signature algorithm this key uses
+// when asked to sign with the given hash.
+func (k *rsaPrivateKey) signatureAlgorithmForHash(hashID crypto.Hash) *signatureAlgorithm {
+	return rsaPKCS1v15SignatureAlgorithmForHashID(hashID)
+}
+
+// signDigest signs an already computed hash of the data to sign.
+func (k *rsaPrivateKey) signDigest(sigAlg *signatureAlgorithm, hash []byte) ([]byte, error) {
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.PrivateKey, sigAlg.HashID(), hash)
+	if err != nil {
+		return nil, fmt.Errorf("error producing signature: %s", err)
+	}
+
+	return signature, nil
+}
+
 // CryptoPrivateKey returns the internal object which can be used as a
 // crypto.PublicKey for use with other standard library operations. The type
 // is either *rsa.PublicKey or *ecdsa.PublicKey
@@ -0,0 +1,116 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// VerificationFailure categorizes why a signature failed verification.
+type VerificationFailure int
+
+const (
+	// FailureMalformed is used when the signature, its public key or its
+	// x509 chain cannot be decoded, or a countersigned signature is
+	// missing.
+	FailureMalformed VerificationFailure = iota + 1
+	// FailureInvalidSignature is used when the signature does not match
+	// the signed content, which has most likely been tampered with.
+	FailureInvalidSignature
+	// FailureUnknownAuthority is used when the x509 chain of the signature
+	// was not issued by a trusted certificate authority.
+	FailureUnknownAuthority
+	// FailureExpiredCertificate is used when a certificate of the x509
+	// chain has expired or is not yet valid.
+	FailureExpiredCertificate
+	// FailureInvalidChain is used when the x509 chain of the signature
+	// fails verification for any other reason.
+	FailureInvalidChain
+	// FailureTimestamp is used when the timestamp token of the signature
+	// is invalid or issued by an untrusted authority.
+	FailureTimestamp
+	// FailureMissingChain is used when a chain is required but the
+	// signature has none.
+	FailureMissingChain
+	// FailureRevoked is used when a certificate of the x509 chain has been
+	// revoked or its revocation status is required but unavailable.
+	FailureRevoked
+)
+
+// String returns a short description of the failure category.
+func (f VerificationFailure) String() string {
+	switch f {
+	case FailureMalformed:
+		return "malformed signature"
+	case FailureInvalidSignature:
+		return "invalid signature"
+	case FailureUnknownAuthority:
+		return "unknown authority"
+	case FailureExpiredCertificate:
+		return "expired certificate"
+	case FailureInvalidChain:
+		return "invalid chain"
+	case FailureTimestamp:
+		return "invalid timestamp"
+	case FailureMissingChain:
+		return "missing chain"
+	case FailureRevoked:
+		return "revoked certificate"
+	default:
+		return fmt.Sprintf("VerificationFailure(%d)", int(f))
+	}
+}
+
+// SignatureError is returned when a signature of a JSONSignature fails
+// verification. It can be retrieved with errors.As from the errors
+// returned by the verification methods.
+type SignatureError struct {
+	// Index is the position of the signature in the JSONSignature.
+	Index int
+	// KeyID is the ID of the key which produced the signature, empty if
+	// the key could not be determined.
+	KeyID string
+	// Failure is the category of the failure.
+	Failure VerificationFailure
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature %d by key %q failed verification: %s", e.Index, e.KeyID, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// verificationFailure returns err categorized as the given failure. The
+// signature index and key are filled in by signatureError.
+func verificationFailure(failure VerificationFailure, err error) error {
+	return &SignatureError{Failure: failure, Err: err}
+}
+
+// chainFailure categorizes an error returned by x509 chain verification.
+func chainFailure(err error) error {
+	switch e := err.(type) {
+	case x509.UnknownAuthorityError:
+		return verificationFailure(FailureUnknownAuthority, err)
+	case x509.CertificateInvalidError:
+		if e.Reason == x509.Expired {
+			return verificationFailure(FailureExpiredCertificate, err)
+		}
+	}
+	return verificationFailure(FailureInvalidChain, err)
+}
+
+// signatureError returns err as a *SignatureError for the signature at the
+// given index. Uncategorized errors are considered malformed signatures.
+func (js *JSONSignature) signatureError(index int, err error) error {
+	sigErr, ok := err.(*SignatureError)
+	if !ok {
+		sigErr = &SignatureError{Failure: FailureMalformed, Err: err}
+	}
+	sigErr.Index = index
+	sigErr.KeyID = js.signatures[index].keyID()
+	return sigErr
+}
@@ -0,0 +1,84 @@
+package libtrust
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+)
+
+func expectSignatureError(t *testing.T, err error, index int, keyID string, failure VerificationFailure) {
+	var sigErr *SignatureError
+	if !errors.As(err, &sigErr) {
+		t.Fatalf("Expected signature error, got %v", err)
+	}
+	if sigErr.Index != index || sigErr.KeyID != keyID || sigErr.Failure != failure {
+		t.Fatalf("Unexpected signature error: index %d, key %q, failure %s", sigErr.Index, sigErr.KeyID, sigErr.Failure)
+	}
+}
+
+func TestSignatureErrors(t *testing.T) {
+	now := time.Now()
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca := generateTestCert(t, caKey.PublicKey(), caKey, nil, now.Add(-time.Hour), now.Add(3*time.Hour), true)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	keys := make([]PrivateKey, 2)
+	for i := range keys {
+		keys[i], err = GenerateECP256PrivateKey()
+		if err != nil {
+			t.Fatalf("Error generating EC key: %s", err)
+		}
+	}
+	// The second certificate expires before the first, so that verifying
+	// later fails for its signature only.
+	longCert := generateTestCert(t, keys[0].PublicKey(), caKey, ca, now.Add(-time.Hour), now.Add(3*time.Hour), false)
+	shortCert := generateTestCert(t, keys[1].PublicKey(), caKey, ca, now.Add(-time.Hour), now.Add(time.Hour), false)
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(keys[0], []*x509.Certificate{longCert}); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	if err := js.SignWithChain(keys[1], []*x509.Certificate{shortCert}); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	index := 1
+	if js.signatures[0].keyID() == keys[1].KeyID() {
+		index = 0
+	}
+
+	if _, err := js.VerifyChains(pool); err != nil {
+		t.Fatalf("Error verifying chains: %s", err)
+	}
+	_, err = js.VerifyChainsWithOptions(ChainVerifyOptions{Roots: pool, CurrentTime: now.Add(2 * time.Hour)})
+	expectSignatureError(t, err, index, keys[1].KeyID(), FailureExpiredCertificate)
+	var invalidErr x509.CertificateInvalidError
+	if !errors.As(err, &invalidErr) || invalidErr.Reason != x509.Expired {
+		t.Fatalf("Expected underlying x509 error, got %v", err)
+	}
+
+	_, err = js.VerifyChains(x509.NewCertPool())
+	expectSignatureError(t, err, 0, js.signatures[0].keyID(), FailureUnknownAuthority)
+
+	results, err := js.VerifyChainsParallel(x509.NewCertPool(), 2)
+	expectSignatureError(t, err, 0, js.signatures[0].keyID(), FailureUnknownAuthority)
+	expectSignatureError(t, results[index].Err, index, keys[1].KeyID(), FailureUnknownAuthority)
+
+	js.payload = joseBase64UrlEncode([]byte(`{"tampered":true}`))
+	_, err = js.Verify()
+	expectSignatureError(t, err, 0, js.signatures[0].keyID(), FailureInvalidSignature)
+
+	js.signatures[1].Signature = "!"
+	_, err = js.VerifyParallel(1)
+	expectSignatureError(t, err, 0, js.signatures[0].keyID(), FailureInvalidSignature)
+	results, _ = js.VerifyParallel(1)
+	expectSignatureError(t, results[1].Err, 1, js.signatures[1].keyID(), FailureMalformed)
+}
@@ -0,0 +1,184 @@
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// ASN.1 structures of RFC 6960 used to create responses.
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	ResponderKeyHash []byte    `asn1:"explicit,tag:2"`
+	ProducedAt       time.Time `asn1:"generalized"`
+	Responses        []ocspSingleResponse
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag       `asn1:"tag:2,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+	NextUpdate time.Time       `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	KeyHash       []byte
+	SerialNumber  *big.Int
+}
+
+var (
+	oidOCSPBasic          = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSHA1               = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSignatureECDSA256  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	errUnsupportedOCSPKey = errors.New("OCSP responder key must be an ECDSA key")
+)
+
+// OCSPResponder issues OCSP responses for the certificates of an issuer,
+// signed with the key of the issuer, so that OCSP stapling can be tested
+// offline.
+type OCSPResponder struct {
+	issuer *x509.Certificate
+	key    crypto.Signer
+
+	// Now returns the time at which responses are produced. It defaults
+	// to time.Now.
+	Now func() time.Time
+	// Validity is the time after which a response is stale. It defaults
+	// to one hour.
+	Validity time.Duration
+
+	lock    sync.Mutex
+	revoked map[string]time.Time
+	unknown map[string]bool
+}
+
+// NewOCSPResponder returns a responder for the certificates issued by the
+// given certificate. The key must be the ECDSA key of the issuer.
+func NewOCSPResponder(issuer *x509.Certificate, key crypto.Signer) *OCSPResponder {
+	return &OCSPResponder{
+		issuer:   issuer,
+		key:      key,
+		Now:      time.Now,
+		Validity: time.Hour,
+		revoked:  make(map[string]time.Time),
+		unknown:  make(map[string]bool),
+	}
+}
+
+// Revoke marks the certificate with the given serial number as revoked at
+// the given time.
+func (r *OCSPResponder) Revoke(serial *big.Int, at time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.revoked[serial.String()] = at
+}
+
+// Forget makes the responder answer that the status of the certificate
+// with the given serial number is unknown.
+func (r *OCSPResponder) Forget(serial *big.Int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.unknown[serial.String()] = true
+}
+
+// Response returns a DER encoded OCSP response for the given certificate.
+func (r *OCSPResponder) Response(cert *x509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(r.issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+	nameHash := sha1.Sum(r.issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+
+	now := r.Now()
+	single := ocspSingleResponse{
+		CertID: ocspCertID{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+			NameHash:      nameHash[:],
+			KeyHash:       keyHash[:],
+			SerialNumber:  cert.SerialNumber,
+		},
+		ThisUpdate: now.UTC(),
+		NextUpdate: now.Add(r.Validity).UTC(),
+	}
+	r.lock.Lock()
+	if revokedAt, ok := r.revoked[cert.SerialNumber.String()]; ok {
+		single.Revoked = ocspRevokedInfo{RevocationTime: revokedAt.UTC()}
+	} else if r.unknown[cert.SerialNumber.String()] {
+		single.Unknown = true
+	} else {
+		single.Good = true
+	}
+	r.lock.Unlock()
+
+	tbs, err := asn1.Marshal(ocspResponseData{
+		ResponderKeyHash: keyHash[:],
+		ProducedAt:       now.UTC(),
+		Responses:        []ocspSingleResponse{single},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := r.key.Public().(*ecdsa.PublicKey); !ok {
+		return nil, errUnsupportedOCSPKey
+	}
+	digest := sha256.Sum256(tbs)
+	signature, err := r.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	basic, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSA256},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponse{
+		Response: ocspResponseBytes{
+			ResponseType: oidOCSPBasic,
+			Response:     basic,
+		},
+	})
+}
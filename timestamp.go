@@ -0,0 +1,250 @@
+package libtrust
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrTimestampMismatch is used when a timestamp token was not issued
+	// for the signature it is attached to.
+	ErrTimestampMismatch = errors.New("timestamp token does not match signature")
+
+	// ErrMissingTimestampChain is used when verifying the authority of a
+	// timestamp token which was not signed with an x509 chain.
+	ErrMissingTimestampChain = errors.New("timestamp authority has no x509 chain")
+)
+
+// Timestamper issues timestamp tokens binding a signature digest to the
+// current time.
+type Timestamper interface {
+	Timestamp(digest string) ([]byte, error)
+}
+
+// TimestampAuthority is a Timestamper which signs timestamp tokens locally
+// using a libtrust private key and optional x509 chain.
+type TimestampAuthority struct {
+	key   PrivateKey
+	chain []*x509.Certificate
+
+	// Now returns the time attested by issued tokens. It defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+// NewTimestampAuthority returns a timestamp authority which signs tokens
+// with the given key. If a chain is given, its leaf certificate must be for
+// the public key of the given key and tokens may then be verified against
+// an x509 pool.
+func NewTimestampAuthority(key PrivateKey, chain []*x509.Certificate) *TimestampAuthority {
+	return &TimestampAuthority{
+		key:   key,
+		chain: chain,
+		Now:   time.Now,
+	}
+}
+
+type timestampPayload struct {
+	Digest string `json:"digest"`
+	Time   string `json:"time"`
+}
+
+// Timestamp issues a timestamp token for the given signature digest. The
+// token is a JWS signed by the authority.
+func (a *TimestampAuthority) Timestamp(digest string) ([]byte, error) {
+	js, err := NewJSONSignatureFromMap(timestampPayload{
+		Digest: digest,
+		Time:   a.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(a.chain) > 0 {
+		err = js.SignWithChain(a.key, a.chain)
+	} else {
+		err = js.Sign(a.key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return js.JWS()
+}
+
+// TimestampToken is a statement by a timestamp authority that a signature
+// existed at a given time.
+type TimestampToken struct {
+	// Digest identifies the timestamped signature.
+	Digest string
+	// Time is the time attested by the authority.
+	Time time.Time
+	// Authority is the public key which signed the token.
+	Authority PublicKey
+	// Chain is the x509 chain of the authority, if the token has one.
+	Chain []*x509.Certificate
+}
+
+// ParseTimestampToken parses a timestamp token and verifies its signature.
+// The authority is not trusted by this alone, see VerifyAuthority.
+func ParseTimestampToken(content []byte) (*TimestampToken, error) {
+	js, err := ParseJWS(content)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timestamp token: %s", err)
+	}
+	if len(js.signatures) != 1 {
+		return nil, errors.New("timestamp token must have exactly one signature")
+	}
+	keys, err := js.Verify()
+	if err != nil {
+		return nil, fmt.Errorf("error verifying timestamp token: %s", err)
+	}
+	chain, err := js.signatures[0].chain()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := js.Payload()
+	if err != nil {
+		return nil, err
+	}
+	var parsed timestampPayload
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return nil, fmt.Errorf("error unmarshalling timestamp token: %s", err)
+	}
+	if parsed.Digest == "" {
+		return nil, errors.New("timestamp token has no digest")
+	}
+	t, err := time.Parse(time.RFC3339, parsed.Time)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp token time: %s", err)
+	}
+
+	return &TimestampToken{
+		Digest:    parsed.Digest,
+		Time:      t,
+		Authority: keys[0],
+		Chain:     chain,
+	}, nil
+}
+
+// VerifyAuthority verifies the x509 chain of the authority against the
+// given pool at the current time and returns the verified chains. The time
+// attested by the token must also fall within the validity period of the
+// authority certificate. Tokens from authorities without a chain must be
+// checked by comparing Authority with a trusted key instead.
+//
+// The chain is not verified at the attested time, which the token itself
+// claims: a token signed with an expired or compromised authority key
+// could otherwise be backdated into the validity period of its
+// certificate.
+func (t *TimestampToken) VerifyAuthority(roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(t.Chain) == 0 {
+		return nil, ErrMissingTimestampChain
+	}
+	if leaf := t.Chain[0]; t.Time.Before(leaf.NotBefore) || t.Time.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("timestamp token time %s is outside the validity of the authority certificate", t.Time.Format(time.RFC3339))
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range t.Chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	return t.Chain[0].Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		Roots:         roots,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+}
+
+// timestamp returns the parsed timestamp token attached to the signature,
+// or nil if it has none.
+func (s *jsSignature) timestamp() (*TimestampToken, error) {
+	if len(s.Header.Timestamp) == 0 {
+		return nil, nil
+	}
+	token, err := ParseTimestampToken(s.Header.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if token.Digest != s.digest() {
+		return nil, ErrTimestampMismatch
+	}
+	return token, nil
+}
+
+// AddTimestamps requests a timestamp token from the given timestamper for
+// each signature which does not have one yet and attaches it to the
+// unprotected header of the signature. The receiver is not modified if any
+// request fails.
+func (js *JSONSignature) AddTimestamps(timestamper Timestamper) error {
+	tokens := make([][]byte, len(js.signatures))
+	for i := range js.signatures {
+		if len(js.signatures[i].Header.Timestamp) > 0 {
+			continue
+		}
+		token, err := timestamper.Timestamp(js.signatures[i].digest())
+		if err != nil {
+			return fmt.Errorf("error timestamping signature %d: %s", i, err)
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, token); err != nil {
+			return fmt.Errorf("invalid timestamp token: %s", err)
+		}
+		tokens[i] = buf.Bytes()
+	}
+	for i, token := range tokens {
+		if token != nil {
+			js.signatures[i].Header.Timestamp = token
+		}
+	}
+	return nil
+}
+
+// Timestamps returns the timestamp token attached to each signature, in
+// signature order, or nil for signatures without one. The tokens are
+// checked against their signature but their authorities are not verified.
+func (js *JSONSignature) Timestamps() ([]*TimestampToken, error) {
+	tokens := make([]*TimestampToken, len(js.signatures))
+	for i := range js.signatures {
+		token, err := js.signatures[i].timestamp()
+		if err != nil {
+			return nil, err
+		}
+		tokens[i] = token
+	}
+	return tokens, nil
+}
+
+// VerifyChainsWithTimestamps verifies all the signatures and the chains
+// associated with each signature like VerifyChains, except that the chain
+// of a signature carrying a timestamp token is verified at the time attested
+// by the token. This accepts certificates which expired after signing. The
+// token must have been issued for the signature by an authority whose chain
+// currently verifies against authorities, as by VerifyAuthority, otherwise
+// the signature fails verification.
+func (js *JSONSignature) VerifyChainsWithTimestamps(ca, authorities *x509.CertPool) ([][]*x509.Certificate, error) {
+	chains := make([][]*x509.Certificate, 0, len(js.signatures))
+	for i := range js.signatures {
+		signature := &js.signatures[i]
+		var signedAt time.Time
+		token, err := signature.timestamp()
+		if err != nil {
+			return nil, js.signatureError(i, verificationFailure(FailureTimestamp, err))
+		}
+		if token != nil {
+			if _, err := token.VerifyAuthority(authorities); err != nil {
+				err = fmt.Errorf("error verifying timestamp authority: %s", err)
+				return nil, js.signatureError(i, verificationFailure(FailureTimestamp, err))
+			}
+			signedAt = token.Time
+		}
+		verifiedChains, err := js.verifySignatureChains(signature, &ChainVerifyOptions{Roots: ca, CurrentTime: signedAt})
+		if err != nil {
+			return nil, js.signatureError(i, err)
+		}
+		chains = append(chains, verifiedChains...)
+	}
+	return chains, nil
+}
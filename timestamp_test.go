@@ -0,0 +1,263 @@
+package libtrust
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func generateTestCert(t *testing.T, key PublicKey, parentKey PrivateKey, parent *x509.Certificate, notBefore, notAfter time.Time, isCA bool) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: key.KeyID(),
+		},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent = template
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, key.CryptoPublicKey(), parentKey.CryptoPrivateKey())
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err)
+	}
+	return cert
+}
+
+func TestTimestampToken(t *testing.T) {
+	authorityKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	authority := NewTimestampAuthority(authorityKey, nil)
+	signedAt := time.Date(2014, 8, 26, 12, 0, 0, 0, time.UTC)
+	authority.Now = func() time.Time { return signedAt }
+
+	raw, err := authority.Timestamp("digest")
+	if err != nil {
+		t.Fatalf("Error issuing timestamp token: %s", err)
+	}
+	token, err := ParseTimestampToken(raw)
+	if err != nil {
+		t.Fatalf("Error parsing timestamp token: %s", err)
+	}
+	if token.Digest != "digest" {
+		t.Fatalf("Unexpected digest: %q", token.Digest)
+	}
+	if !token.Time.Equal(signedAt) {
+		t.Fatalf("Unexpected time: %s", token.Time)
+	}
+	if token.Authority.KeyID() != authorityKey.KeyID() {
+		t.Fatalf("Unexpected authority: %s", token.Authority.KeyID())
+	}
+	if _, err := token.VerifyAuthority(x509.NewCertPool()); err != ErrMissingTimestampChain {
+		t.Fatalf("Expected missing chain error, got %v", err)
+	}
+
+	js, err := ParseJWS(raw)
+	if err != nil {
+		t.Fatalf("Error parsing timestamp token: %s", err)
+	}
+	js.payload = joseBase64UrlEncode([]byte(`{"digest":"other","time":"2014-08-26T12:00:00Z"}`))
+	tampered, err := js.JWS()
+	if err != nil {
+		t.Fatalf("Error serializing tampered token: %s", err)
+	}
+	if _, err := ParseTimestampToken(tampered); err == nil {
+		t.Fatalf("Expected error parsing tampered timestamp token")
+	}
+}
+
+func TestVerifyChainsWithTimestamps(t *testing.T) {
+	now := time.Now()
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca := generateTestCert(t, caKey.PublicKey(), caKey, nil, now.Add(-72*time.Hour), now.Add(time.Hour), true)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	// The signing certificate was valid from two days ago until yesterday.
+	signerKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	signerCert := generateTestCert(t, signerKey.PublicKey(), caKey, ca, now.Add(-48*time.Hour), now.Add(-24*time.Hour), false)
+
+	authorityKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	authorityCert := generateTestCert(t, authorityKey.PublicKey(), caKey, ca, now.Add(-72*time.Hour), now.Add(time.Hour), false)
+	authority := NewTimestampAuthority(authorityKey, []*x509.Certificate{authorityCert})
+	authority.Now = func() time.Time { return now.Add(-36 * time.Hour) }
+
+	testMap, _ := createTestJSON("", "")
+	js, err := NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	// This test deliberately needs a chain which has expired by the time of
+	// signing, which SignWithChain refuses: sign as it would have while the
+	// certificate was valid.
+	if err := js.sign(signerKey, chainHeader([]*x509.Certificate{signerCert}), nil); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+
+	if _, err := js.VerifyChains(pool); err == nil {
+		t.Fatalf("Expected expired certificate to fail verification")
+	}
+	if _, err := js.VerifyChainsWithTimestamps(pool, pool); err == nil {
+		t.Fatalf("Expected expired certificate without timestamp to fail verification")
+	}
+
+	if err := js.AddTimestamps(authority); err != nil {
+		t.Fatalf("Error adding timestamps: %s", err)
+	}
+
+	// Timestamps must survive serialization.
+	b, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatalf("Error formatting signature: %s", err)
+	}
+	parsed, err := ParsePrettySignature(b, "signatures")
+	if err != nil {
+		t.Fatalf("Error parsing signature: %s", err)
+	}
+
+	chains, err := parsed.VerifyChainsWithTimestamps(pool, pool)
+	if err != nil {
+		t.Fatalf("Error verifying timestamped signature: %s", err)
+	}
+	if len(chains) != 1 || !chains[0][0].Equal(signerCert) {
+		t.Fatalf("Unexpected verified chains: %v", chains)
+	}
+
+	tokens, err := parsed.Timestamps()
+	if err != nil {
+		t.Fatalf("Error getting timestamps: %s", err)
+	}
+	if len(tokens) != 1 || !tokens[0].Time.Equal(authority.Now().Truncate(time.Second)) {
+		t.Fatalf("Unexpected timestamps: %v", tokens)
+	}
+
+	if _, err := parsed.VerifyChainsWithTimestamps(pool, x509.NewCertPool()); err == nil {
+		t.Fatalf("Expected untrusted timestamp authority to fail verification")
+	}
+
+	// A token issued for another signature must not be accepted.
+	otherKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	if err := parsed.Sign(otherKey); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	for i := range parsed.signatures {
+		parsed.signatures[i].Header.Timestamp = js.signatures[0].Header.Timestamp
+	}
+	if _, err := parsed.Timestamps(); err != ErrTimestampMismatch {
+		t.Fatalf("Expected timestamp mismatch error, got %v", err)
+	}
+
+	// Signatures made with SignWithChain while the certificate is valid are
+	// verified at the time of their timestamp.
+	validCert := generateTestCert(t, signerKey.PublicKey(), caKey, ca, now.Add(-time.Hour), now.Add(time.Hour), false)
+	js, err = NewJSONSignatureFromMap(testMap)
+	if err != nil {
+		t.Fatalf("Error creating JSON signature: %s", err)
+	}
+	if err := js.SignWithChain(signerKey, []*x509.Certificate{validCert}); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	authority.Now = time.Now
+	if err := js.AddTimestamps(authority); err != nil {
+		t.Fatalf("Error adding timestamps: %s", err)
+	}
+	chains, err = js.VerifyChainsWithTimestamps(pool, pool)
+	if err != nil {
+		t.Fatalf("Error verifying timestamped signature: %s", err)
+	}
+	if len(chains) != 1 || !chains[0][0].Equal(validCert) {
+		t.Fatalf("Unexpected verified chains: %v", chains)
+	}
+}
+
+func TestVerifyAuthorityTime(t *testing.T) {
+	now := time.Now()
+	caKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	ca := generateTestCert(t, caKey.PublicKey(), caKey, nil, now.Add(-72*time.Hour), now.Add(time.Hour), true)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	authorityKey, err := GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+
+	issue := func(authorityCert *x509.Certificate, at time.Time) *TimestampToken {
+		authority := NewTimestampAuthority(authorityKey, []*x509.Certificate{authorityCert})
+		authority.Now = func() time.Time { return at }
+		raw, err := authority.Timestamp("digest")
+		if err != nil {
+			t.Fatalf("Error issuing timestamp token: %s", err)
+		}
+		token, err := ParseTimestampToken(raw)
+		if err != nil {
+			t.Fatalf("Error parsing timestamp token: %s", err)
+		}
+		return token
+	}
+
+	validCert := generateTestCert(t, authorityKey.PublicKey(), caKey, ca, now.Add(-48*time.Hour), now.Add(time.Hour), false)
+	if _, err := issue(validCert, now.Add(-36*time.Hour)).VerifyAuthority(pool); err != nil {
+		t.Fatalf("Error verifying timestamp authority: %s", err)
+	}
+	if _, err := issue(validCert, now.Add(-60*time.Hour)).VerifyAuthority(pool); err == nil {
+		t.Fatalf("Expected error verifying token predating the authority certificate")
+	}
+
+	// A token backdated into the validity of an expired authority
+	// certificate must not be accepted. TimestampAuthority refuses to sign
+	// with the expired chain, so the token is forged with the private
+	// signing method as a holder of the old key would.
+	expiredCert := generateTestCert(t, authorityKey.PublicKey(), caKey, ca, now.Add(-48*time.Hour), now.Add(-24*time.Hour), false)
+	js, err := NewJSONSignatureFromMap(timestampPayload{
+		Digest: "digest",
+		Time:   now.Add(-36 * time.Hour).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("Error creating timestamp token: %s", err)
+	}
+	if err := js.sign(authorityKey, chainHeader([]*x509.Certificate{expiredCert}), nil); err != nil {
+		t.Fatalf("Error signing timestamp token: %s", err)
+	}
+	raw, err := js.JWS()
+	if err != nil {
+		t.Fatalf("Error serializing timestamp token: %s", err)
+	}
+	backdated, err := ParseTimestampToken(raw)
+	if err != nil {
+		t.Fatalf("Error parsing timestamp token: %s", err)
+	}
+	if _, err := backdated.VerifyAuthority(pool); err == nil {
+		t.Fatalf("Expected error verifying backdated token of an expired authority")
+	}
+}
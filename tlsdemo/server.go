@@ -18,10 +18,14 @@ var (
 )
 
 func requestHandler(w http.ResponseWriter, r *http.Request) {
-	clientCert := r.TLS.PeerCertificates[0]
-	keyID := clientCert.Subject.CommonName
+	clientKey, err := libtrust.PeerIdentity(r.TLS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	keyID := clientKey.KeyID()
 	log.Printf("Request from keyID: %s\n", keyID)
-	fmt.Fprintf(w, "Hello, client! I'm a server! And you are %T: %s.\n", clientCert.PublicKey, html.EscapeString(keyID))
+	fmt.Fprintf(w, "Hello, client! I'm a server! And you are %T: %s.\n", clientKey.CryptoPublicKey(), html.EscapeString(keyID))
 }
 
 func main() {
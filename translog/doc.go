@@ -0,0 +1,9 @@
+/*
+Package translog implements an append-only, tamper-evident log of issued
+identities in the style of Certificate Transparency (RFC 9162). Entries such
+as x509 certificates, trust statements and JSON signatures are the leaves of
+a Merkle tree. The log publishes signed tree heads, signed with a libtrust
+key, and produces inclusion and consistency proofs which clients verify
+offline against those heads.
+*/
+package translog
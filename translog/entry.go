@@ -0,0 +1,92 @@
+package translog
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/docker/libtrust"
+	"github.com/docker/libtrust/trustgraph"
+)
+
+// EntryType identifies the kind of content of a log entry.
+type EntryType byte
+
+const (
+	// EntryCertificate is a DER encoded x509 certificate.
+	EntryCertificate EntryType = iota + 1
+	// EntryStatement is a signed trust graph statement.
+	EntryStatement
+	// EntrySignature is a JSON signature in JWS JSON serialization.
+	EntrySignature
+)
+
+// String returns the name of the entry type.
+func (t EntryType) String() string {
+	switch t {
+	case EntryCertificate:
+		return "certificate"
+	case EntryStatement:
+		return "statement"
+	case EntrySignature:
+		return "signature"
+	default:
+		return fmt.Sprintf("EntryType(%d)", byte(t))
+	}
+}
+
+// Entry is an entry of the log.
+type Entry struct {
+	Type EntryType
+	Data []byte
+}
+
+// CertificateEntry returns an entry recording an issued certificate.
+func CertificateEntry(cert *x509.Certificate) Entry {
+	return Entry{Type: EntryCertificate, Data: cert.Raw}
+}
+
+// StatementEntry returns an entry recording a signed trust statement.
+func StatementEntry(statement *trustgraph.Statement) (Entry, error) {
+	data, err := statement.Bytes()
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Type: EntryStatement, Data: data}, nil
+}
+
+// SignatureEntry returns an entry recording a JSON signature.
+func SignatureEntry(js *libtrust.JSONSignature) (Entry, error) {
+	data, err := js.JWS()
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Type: EntrySignature, Data: data}, nil
+}
+
+// Certificate returns the certificate recorded by a certificate entry.
+func (e Entry) Certificate() (*x509.Certificate, error) {
+	if e.Type != EntryCertificate {
+		return nil, fmt.Errorf("entry is a %s, not a certificate", e.Type)
+	}
+	return x509.ParseCertificate(e.Data)
+}
+
+// marshal returns the leaf data of the entry: its type followed by its
+// content.
+func (e Entry) marshal() []byte {
+	return append([]byte{byte(e.Type)}, e.Data...)
+}
+
+// unmarshalEntry parses leaf data.
+func unmarshalEntry(leaf []byte) (Entry, error) {
+	if len(leaf) == 0 {
+		return Entry{}, errors.New("empty log entry")
+	}
+	return Entry{Type: EntryType(leaf[0]), Data: leaf[1:]}, nil
+}
+
+// LeafHash returns the Merkle tree hash of the entry.
+func (e Entry) LeafHash() []byte {
+	return LeafHash(e.marshal())
+}
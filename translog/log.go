@@ -0,0 +1,201 @@
+package translog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/libtrust"
+)
+
+// ErrUntrustedTreeHead is returned when a signed tree head is not signed
+// by the expected log key.
+var ErrUntrustedTreeHead = errors.New("tree head not signed by log key")
+
+// Log is an append-only Merkle tree log of entries whose tree heads are
+// signed with a libtrust key.
+type Log struct {
+	store Store
+	key   libtrust.PrivateKey
+
+	// Now returns the timestamp of signed tree heads. It defaults to
+	// time.Now.
+	Now func() time.Time
+
+	leafLock sync.RWMutex
+	leaves   [][]byte
+	hashes   [][]byte
+}
+
+// Open returns the log persisted in the given store, signing tree heads
+// with the given key.
+func Open(store Store, key libtrust.PrivateKey) (*Log, error) {
+	leaves, err := store.Leaves()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read log: %s", err)
+	}
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = LeafHash(leaf)
+	}
+	return &Log{
+		store:  store,
+		key:    key,
+		Now:    time.Now,
+		leaves: leaves,
+		hashes: hashes,
+	}, nil
+}
+
+// Append adds an entry to the log and returns its index.
+func (l *Log) Append(entry Entry) (uint64, error) {
+	leaf := entry.marshal()
+
+	l.leafLock.Lock()
+	defer l.leafLock.Unlock()
+	if err := l.store.Append(leaf); err != nil {
+		return 0, fmt.Errorf("unable to append to log: %s", err)
+	}
+	l.leaves = append(l.leaves, leaf)
+	l.hashes = append(l.hashes, LeafHash(leaf))
+	return uint64(len(l.leaves) - 1), nil
+}
+
+// Size returns the number of entries in the log.
+func (l *Log) Size() uint64 {
+	l.leafLock.RLock()
+	defer l.leafLock.RUnlock()
+	return uint64(len(l.leaves))
+}
+
+// Entry returns the entry at the given index.
+func (l *Log) Entry(index uint64) (Entry, error) {
+	l.leafLock.RLock()
+	defer l.leafLock.RUnlock()
+	if index >= uint64(len(l.leaves)) {
+		return Entry{}, ErrIndexOutOfRange
+	}
+	return unmarshalEntry(l.leaves[index])
+}
+
+// RootHash returns the root hash of the tree of the first treeSize entries.
+func (l *Log) RootHash(treeSize uint64) ([]byte, error) {
+	l.leafLock.RLock()
+	defer l.leafLock.RUnlock()
+	if treeSize > uint64(len(l.hashes)) {
+		return nil, ErrIndexOutOfRange
+	}
+	return rootHash(l.hashes[:treeSize]), nil
+}
+
+// InclusionProof returns the proof that the entry at the given index is in
+// the tree of the first treeSize entries.
+func (l *Log) InclusionProof(index, treeSize uint64) ([][]byte, error) {
+	l.leafLock.RLock()
+	defer l.leafLock.RUnlock()
+	if treeSize > uint64(len(l.hashes)) || index >= treeSize {
+		return nil, ErrIndexOutOfRange
+	}
+	return inclusionPath(index, l.hashes[:treeSize]), nil
+}
+
+// ConsistencyProof returns the proof that the tree of the first firstSize
+// entries is a prefix of the tree of the first secondSize entries.
+func (l *Log) ConsistencyProof(firstSize, secondSize uint64) ([][]byte, error) {
+	l.leafLock.RLock()
+	defer l.leafLock.RUnlock()
+	if secondSize > uint64(len(l.hashes)) || firstSize > secondSize {
+		return nil, ErrIndexOutOfRange
+	}
+	if firstSize == 0 {
+		return nil, nil
+	}
+	return consistencyPath(firstSize, l.hashes[:secondSize], true), nil
+}
+
+// SignedTreeHead is the root hash of the log at a given size, signed by the
+// log key.
+type SignedTreeHead struct {
+	TreeSize  uint64    `json:"treeSize"`
+	RootHash  []byte    `json:"rootHash"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// JWS is the signed tree head in JWS JSON serialization, to be
+	// published to clients.
+	JWS []byte `json:"-"`
+}
+
+// SignedTreeHead returns a tree head of the current log, signed with the
+// log key.
+func (l *Log) SignedTreeHead() (*SignedTreeHead, error) {
+	l.leafLock.RLock()
+	sth := &SignedTreeHead{
+		TreeSize:  uint64(len(l.hashes)),
+		RootHash:  rootHash(l.hashes),
+		Timestamp: l.Now().UTC(),
+	}
+	l.leafLock.RUnlock()
+
+	payload, err := json.Marshal(sth)
+	if err != nil {
+		return nil, err
+	}
+	js, err := libtrust.NewJSONSignature(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := js.Sign(l.key); err != nil {
+		return nil, err
+	}
+	if sth.JWS, err = js.JWS(); err != nil {
+		return nil, err
+	}
+	return sth, nil
+}
+
+// ParseSignedTreeHead parses a tree head in JWS JSON serialization and
+// checks that it is signed by the given log key.
+func ParseSignedTreeHead(content []byte, logKey libtrust.PublicKey) (*SignedTreeHead, error) {
+	js, err := libtrust.ParseJWS(content)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := js.Verify()
+	if err != nil {
+		return nil, err
+	}
+	trusted := false
+	for _, key := range keys {
+		if key.KeyID() == logKey.KeyID() {
+			trusted = true
+		}
+	}
+	if !trusted {
+		return nil, ErrUntrustedTreeHead
+	}
+
+	payload, err := js.Payload()
+	if err != nil {
+		return nil, err
+	}
+	var sth SignedTreeHead
+	if err := json.Unmarshal(payload, &sth); err != nil {
+		return nil, fmt.Errorf("malformed tree head: %s", err)
+	}
+	sth.JWS = content
+	return &sth, nil
+}
+
+// VerifyInclusion checks that the entry is at the given index of the tree
+// described by the tree head.
+func (sth *SignedTreeHead) VerifyInclusion(entry Entry, index uint64, proof [][]byte) error {
+	return VerifyInclusion(entry.LeafHash(), index, sth.TreeSize, proof, sth.RootHash)
+}
+
+// VerifyConsistency checks that the tree described by an earlier tree head
+// is a prefix of the tree described by this one.
+func (sth *SignedTreeHead) VerifyConsistency(earlier *SignedTreeHead, proof [][]byte) error {
+	return VerifyConsistency(earlier.TreeSize, sth.TreeSize, earlier.RootHash, sth.RootHash, proof)
+}
@@ -0,0 +1,245 @@
+package translog
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust"
+	"github.com/docker/libtrust/trustgraph"
+)
+
+func TestLog(t *testing.T) {
+	logKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	dir, err := ioutil.TempDir("", "libtrust-translog")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "log")
+
+	store, err := OpenFileStore(filename)
+	if err != nil {
+		t.Fatalf("Error opening store: %s", err)
+	}
+	log, err := Open(store, logKey)
+	if err != nil {
+		t.Fatalf("Error opening log: %s", err)
+	}
+	empty, err := log.SignedTreeHead()
+	if err != nil {
+		t.Fatalf("Error signing tree head: %s", err)
+	}
+
+	// Log a certificate, a statement and a signature.
+	cert, err := libtrust.GenerateSelfSignedClientCert(logKey)
+	if err != nil {
+		t.Fatalf("Error generating certificate: %s", err)
+	}
+	grants := bytes.NewBufferString(`[{"subject":"/library","permission":15,"grantee":"` + logKey.KeyID() + `"}]`)
+	statement, err := trustgraph.CreateStatement(grants, bytes.NewBufferString("[]"), time.Hour, logKey, []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatalf("Error creating statement: %s", err)
+	}
+	statementEntry, err := StatementEntry(statement)
+	if err != nil {
+		t.Fatalf("Error creating statement entry: %s", err)
+	}
+	js, err := libtrust.NewJSONSignature([]byte(`{"name":"example"}`))
+	if err != nil {
+		t.Fatalf("Error creating signature: %s", err)
+	}
+	if err := js.Sign(logKey); err != nil {
+		t.Fatalf("Error signing content: %s", err)
+	}
+	signatureEntry, err := SignatureEntry(js)
+	if err != nil {
+		t.Fatalf("Error creating signature entry: %s", err)
+	}
+
+	entries := []Entry{CertificateEntry(cert), statementEntry, signatureEntry}
+	for i, entry := range entries {
+		index, err := log.Append(entry)
+		if err != nil {
+			t.Fatalf("Error appending entry: %s", err)
+		}
+		if index != uint64(i) {
+			t.Fatalf("Unexpected index %d for entry %d", index, i)
+		}
+	}
+	first, err := log.SignedTreeHead()
+	if err != nil {
+		t.Fatalf("Error signing tree head: %s", err)
+	}
+	store.Close()
+
+	// The log survives reopening.
+	store, err = OpenFileStore(filename)
+	if err != nil {
+		t.Fatalf("Error reopening store: %s", err)
+	}
+	defer store.Close()
+	log, err = Open(store, logKey)
+	if err != nil {
+		t.Fatalf("Error reopening log: %s", err)
+	}
+	if log.Size() != 3 {
+		t.Fatalf("Expected 3 entries after reopening, got %d", log.Size())
+	}
+	entry, err := log.Entry(0)
+	if err != nil {
+		t.Fatalf("Error getting entry: %s", err)
+	}
+	logged, err := entry.Certificate()
+	if err != nil || !logged.Equal(cert) {
+		t.Fatalf("Unexpected logged certificate: %v", err)
+	}
+	if _, err := log.Entry(3); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected index out of range error, got %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := log.Append(CertificateEntry(cert)); err != nil {
+			t.Fatalf("Error appending entry: %s", err)
+		}
+	}
+	second, err := log.SignedTreeHead()
+	if err != nil {
+		t.Fatalf("Error signing tree head: %s", err)
+	}
+
+	// Clients verify the published tree heads and proofs offline.
+	firstHead, err := ParseSignedTreeHead(first.JWS, logKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Error parsing tree head: %s", err)
+	}
+	secondHead, err := ParseSignedTreeHead(second.JWS, logKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Error parsing tree head: %s", err)
+	}
+	emptyHead, err := ParseSignedTreeHead(empty.JWS, logKey.PublicKey())
+	if err != nil {
+		t.Fatalf("Error parsing tree head: %s", err)
+	}
+	for i, entry := range entries {
+		proof, err := log.InclusionProof(uint64(i), firstHead.TreeSize)
+		if err != nil {
+			t.Fatalf("Error getting inclusion proof: %s", err)
+		}
+		if err := firstHead.VerifyInclusion(entry, uint64(i), proof); err != nil {
+			t.Fatalf("Error verifying inclusion of entry %d: %s", i, err)
+		}
+		if err := firstHead.VerifyInclusion(entries[(i+1)%len(entries)], uint64(i), proof); err == nil {
+			t.Fatalf("Expected error verifying inclusion of wrong entry")
+		}
+	}
+	proof, err := log.ConsistencyProof(firstHead.TreeSize, secondHead.TreeSize)
+	if err != nil {
+		t.Fatalf("Error getting consistency proof: %s", err)
+	}
+	if err := secondHead.VerifyConsistency(firstHead, proof); err != nil {
+		t.Fatalf("Error verifying consistency: %s", err)
+	}
+	proof, err = log.ConsistencyProof(0, secondHead.TreeSize)
+	if err != nil {
+		t.Fatalf("Error getting consistency proof: %s", err)
+	}
+	if err := secondHead.VerifyConsistency(emptyHead, proof); err != nil {
+		t.Fatalf("Error verifying consistency with empty tree: %s", err)
+	}
+	if _, err := log.InclusionProof(0, secondHead.TreeSize+1); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected index out of range error, got %v", err)
+	}
+
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("Error generating EC key: %s", err)
+	}
+	if _, err := ParseSignedTreeHead(first.JWS, otherKey.PublicKey()); err != ErrUntrustedTreeHead {
+		t.Fatalf("Expected untrusted tree head error, got %v", err)
+	}
+}
+
+func TestFileStoreTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libtrust-translog")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "log")
+	// A complete record followed by an interrupted one.
+	if err := ioutil.WriteFile(filename, []byte{0, 0, 0, 1, 9, 0, 0, 0, 5, 1, 2}, 0600); err != nil {
+		t.Fatalf("Error writing log file: %s", err)
+	}
+	store, err := OpenFileStore(filename)
+	if err != nil {
+		t.Fatalf("Error opening store: %s", err)
+	}
+	leaves, err := store.Leaves()
+	if err != nil {
+		t.Fatalf("Error reading truncated log: %s", err)
+	}
+	if len(leaves) != 1 || !bytes.Equal(leaves[0], []byte{9}) {
+		t.Fatalf("Unexpected leaves of truncated log: %v", leaves)
+	}
+
+	// Appends land after the complete records.
+	if err := store.Append([]byte{7, 8}); err != nil {
+		t.Fatalf("Error appending leaf: %s", err)
+	}
+	store.Close()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Error reading log file: %s", err)
+	}
+	if expected := []byte{0, 0, 0, 1, 9, 0, 0, 0, 2, 7, 8}; !bytes.Equal(content, expected) {
+		t.Fatalf("Unexpected log file content %v, expected %v", content, expected)
+	}
+}
+
+func TestFileStoreFailedAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libtrust-translog")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "log")
+	store, err := OpenFileStore(filename)
+	if err != nil {
+		t.Fatalf("Error opening store: %s", err)
+	}
+	defer store.Close()
+	if err := store.Append([]byte{9}); err != nil {
+		t.Fatalf("Error appending leaf: %s", err)
+	}
+
+	// Through a read-only file both the write and its rollback fail.
+	file := store.file
+	readOnly, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Error opening log file: %s", err)
+	}
+	store.file = readOnly
+	if err := store.Append([]byte{7, 8}); err == nil {
+		t.Fatalf("Expected error appending to read-only file")
+	}
+	store.file = file
+	readOnly.Close()
+
+	if err := store.Append([]byte{7, 8}); err == nil {
+		t.Fatalf("Expected error appending after failed rollback")
+	}
+	leaves, err := store.Leaves()
+	if err != nil {
+		t.Fatalf("Error reading leaves: %s", err)
+	}
+	if len(leaves) != 1 || !bytes.Equal(leaves[0], []byte{9}) {
+		t.Fatalf("Unexpected leaves after failed append: %v", leaves)
+	}
+}
@@ -0,0 +1,78 @@
+package translog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func testLeafHashes(n int) [][]byte {
+	hashes := make([][]byte, n)
+	for i := range hashes {
+		hashes[i] = LeafHash([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	return hashes
+}
+
+func TestRootHash(t *testing.T) {
+	if hex.EncodeToString(rootHash(nil)) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatalf("Unexpected empty root hash")
+	}
+	leaves := testLeafHashes(3)
+	expected := nodeHash(nodeHash(leaves[0], leaves[1]), leaves[2])
+	if !bytes.Equal(rootHash(leaves), expected) {
+		t.Fatalf("Unexpected root hash of 3 leaves")
+	}
+}
+
+func TestInclusionProofs(t *testing.T) {
+	leaves := testLeafHashes(20)
+	for n := uint64(1); n <= uint64(len(leaves)); n++ {
+		root := rootHash(leaves[:n])
+		for m := uint64(0); m < n; m++ {
+			proof := inclusionPath(m, leaves[:n])
+			if err := VerifyInclusion(leaves[m], m, n, proof, root); err != nil {
+				t.Fatalf("Error verifying inclusion of %d in %d: %s", m, n, err)
+			}
+			if err := VerifyInclusion(leaves[(m+1)%n], m, n, proof, root); n > 1 && err == nil {
+				t.Fatalf("Expected error verifying inclusion of wrong leaf at %d in %d", m, n)
+			}
+			if len(proof) > 0 {
+				if err := VerifyInclusion(leaves[m], m, n, proof[:len(proof)-1], root); err == nil {
+					t.Fatalf("Expected error verifying truncated inclusion proof of %d in %d", m, n)
+				}
+			}
+		}
+		if err := VerifyInclusion(leaves[0], n, n, nil, root); err != ErrIndexOutOfRange {
+			t.Fatalf("Expected index out of range error, got %v", err)
+		}
+	}
+}
+
+func TestConsistencyProofs(t *testing.T) {
+	leaves := testLeafHashes(20)
+	for n := uint64(1); n <= uint64(len(leaves)); n++ {
+		secondRoot := rootHash(leaves[:n])
+		for m := uint64(1); m <= n; m++ {
+			firstRoot := rootHash(leaves[:m])
+			proof := consistencyPath(m, leaves[:n], true)
+			if err := VerifyConsistency(m, n, firstRoot, secondRoot, proof); err != nil {
+				t.Fatalf("Error verifying consistency of %d and %d: %s", m, n, err)
+			}
+			if m < n {
+				if err := VerifyConsistency(m, n, leaves[0], secondRoot, proof); m > 1 && err == nil {
+					t.Fatalf("Expected error verifying consistency of %d and %d with wrong root", m, n)
+				}
+				tampered := append([][]byte{}, proof...)
+				tampered[len(tampered)-1] = leaves[0]
+				if err := VerifyConsistency(m, n, firstRoot, secondRoot, tampered); err == nil {
+					t.Fatalf("Expected error verifying tampered consistency proof of %d and %d", m, n)
+				}
+			}
+		}
+	}
+	if err := VerifyConsistency(2, 1, nil, nil, nil); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected index out of range error, got %v", err)
+	}
+}
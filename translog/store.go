@@ -0,0 +1,160 @@
+package translog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Store persists the leaf data of a log in order. Implementations must be
+// safe for concurrent use.
+type Store interface {
+	// Append stores the data of the next leaf.
+	Append(leaf []byte) error
+	// Leaves returns the data of all the stored leaves.
+	Leaves() ([][]byte, error)
+}
+
+// MemoryStore is a Store keeping leaves in memory.
+type MemoryStore struct {
+	leafLock sync.RWMutex
+	leaves   [][]byte
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append stores the data of the next leaf.
+func (s *MemoryStore) Append(leaf []byte) error {
+	s.leafLock.Lock()
+	defer s.leafLock.Unlock()
+	s.leaves = append(s.leaves, append([]byte(nil), leaf...))
+	return nil
+}
+
+// Leaves returns the data of all the stored leaves.
+func (s *MemoryStore) Leaves() ([][]byte, error) {
+	s.leafLock.RLock()
+	defer s.leafLock.RUnlock()
+	return append([][]byte(nil), s.leaves...), nil
+}
+
+// FileStore is a Store appending leaves to a file, each preceded by its
+// length as a 4 byte big-endian integer.
+type FileStore struct {
+	fileLock sync.Mutex
+	file     *os.File
+	// failed is set when a failed append could not be rolled back, leaving
+	// a partial record which further appends would corrupt.
+	failed error
+}
+
+// OpenFileStore opens the store in the given file, creating it if it does
+// not exist. An incomplete last record, left by an append interrupted by a
+// crash, is removed: it was never reported as stored.
+func OpenFileStore(filename string) (*FileStore, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, size := parseRecords(content); size < int64(len(content)) {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &FileStore{file: f}, nil
+}
+
+// Append writes the data of the next leaf and syncs the file. If either
+// fails, the file is truncated back to its previous records. If that fails
+// too, the store refuses any further append, as the file may end with a
+// partial record; reopening it removes that record.
+func (s *FileStore) Append(leaf []byte) error {
+	record := make([]byte, 4+len(leaf))
+	binary.BigEndian.PutUint32(record, uint32(len(leaf)))
+	copy(record[4:], leaf)
+
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	if s.failed != nil {
+		return fmt.Errorf("store failed after an earlier append: %s", s.failed)
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(record)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		truncErr := s.file.Truncate(info.Size())
+		if truncErr == nil {
+			truncErr = s.file.Sync()
+		}
+		if truncErr != nil {
+			s.failed = fmt.Errorf("unable to remove partial record: %s", truncErr)
+			return fmt.Errorf("%s (%s)", err, s.failed)
+		}
+		return err
+	}
+	return nil
+}
+
+// Leaves reads the data of all the stored leaves.
+func (s *FileStore) Leaves() ([][]byte, error) {
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(s.file)
+	if err != nil {
+		return nil, err
+	}
+	leaves, size := parseRecords(content)
+	if size < int64(len(content)) {
+		return nil, errors.New("truncated log file")
+	}
+	return leaves, nil
+}
+
+// parseRecords returns the leaves of the complete records at the start of
+// the content and their total size.
+func parseRecords(content []byte) ([][]byte, int64) {
+	var (
+		leaves [][]byte
+		size   int64
+	)
+	for len(content) >= 4 {
+		length := binary.BigEndian.Uint32(content)
+		if uint64(len(content)-4) < uint64(length) {
+			break
+		}
+		leaves = append(leaves, content[4:4+length])
+		content = content[4+length:]
+		size += 4 + int64(length)
+	}
+	return leaves, size
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
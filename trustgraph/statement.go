@@ -62,6 +62,9 @@ func (s *Statement) Bytes() ([]byte, error) {
 }
 
 // LoadStatement loads and verifies a statement from an input stream.
+// Verification failures are returned as a *libtrust.SignatureError, which
+// wraps any crypto/x509 chain error; use errors.As rather than a type
+// assertion to retrieve it.
 func LoadStatement(r io.Reader, authority *x509.CertPool) (*Statement, error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
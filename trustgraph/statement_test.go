@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -110,11 +111,13 @@ func TestLoadStatement(t *testing.T) {
 		t.Fatalf("Unexpected grant length\n\tExpected: %d\n\tActual: %d", grantCount, len(s2.Grants))
 	}
 
+	// Chain errors are wrapped in a *libtrust.SignatureError, so the x509
+	// error is retrieved with errors.As rather than a type assertion.
 	pool := x509.NewCertPool()
 	_, err = LoadStatement(bytes.NewReader(statementBytes), pool)
 	if err == nil {
 		t.Fatalf("No error thrown verifying without an authority")
-	} else if _, ok := err.(x509.UnknownAuthorityError); !ok {
+	} else if !errors.As(err, &x509.UnknownAuthorityError{}) {
 		t.Fatalf("Unexpected error verifying without authority: %s", err)
 	}
 
@@ -197,13 +197,26 @@ func keyIDFromCryptoKey(pubKey PublicKey) string {
 	//   SHA256(DER encoded ASN1)
 	// Then truncated to 240 bits and encoded into 12 base32 groups like so:
 	//   ABCD:EFGH:IJKL:MNOP:QRST:UVWX:YZ23:4567:ABCD:EFGH:IJKL:MNOP
+	hash := keyIDHash(pubKey)
+	if hash == nil {
+		return ""
+	}
+	return keyIDEncode(hash)
+}
+
+// keyIDHashLength is the length of the hash encoded in key IDs.
+const keyIDHashLength = 30
+
+// keyIDHash returns the truncated hash encoded by the key ID of a public
+// key, or nil if the key cannot be marshalled.
+func keyIDHash(pubKey PublicKey) []byte {
 	derBytes, err := x509.MarshalPKIXPublicKey(pubKey.CryptoPublicKey())
 	if err != nil {
-		return ""
+		return nil
 	}
 	hasher := crypto.SHA256.New()
 	hasher.Write(derBytes)
-	return keyIDEncode(hasher.Sum(nil)[:30])
+	return hasher.Sum(nil)[:keyIDHashLength]
 }
 
 func stringFromMap(m map[string]interface{}, key string) (string, error) {